@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// saltEnv is the environment variable holding the salt learner names and
+// emails are hashed with by -anonymise.
+const saltEnv = "DRIVING_SALT"
+
+// Redaction markers, replacing personal data found in comments.
+const (
+	redactedEmail = "[email]"
+	redactedPhone = "[phone]"
+	redactedName  = "[name]"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+
+	// phonePattern matches candidate phone numbers, such as 555-123-4567,
+	// (555) 123 4567 or +44 20 7946 0958. redactPhone checks the number
+	// of digits, so that other numbers are left alone.
+	phonePattern = regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{1,4}\)[\s.-]?)?\d{2,4}(?:[\s.-]?\d{2,4}){1,4}`)
+)
+
+// An Anonymiser removes personal data from surveys, so that reports on them
+// can be shared outside the training team.
+type Anonymiser struct {
+	// Salt keys the hash learner names and emails are replaced with. If it
+	// is empty, they are dropped instead: an unsalted hash of a known
+	// email is easily reversed.
+	Salt string
+
+	// Names are redacted from comments, as are the learners' own names.
+	// Each word of a name is redacted on its own too, so that "Thanks
+	// John" does not give away John Smith.
+	Names []string
+}
+
+// Anonymise replaces the names and emails of surveys with pseudonyms, and
+// redacts emails, phone numbers and names from their comments, in place.
+// A learner keeps the same pseudonym across runs with the same Salt.
+func (a Anonymiser) Anonymise(surveys []*Survey) {
+	names := append([]string{}, a.Names...)
+	for _, s := range surveys {
+		names = append(names, s.Name)
+	}
+	namePattern := wordsPattern(nameWords(names))
+
+	for _, s := range surveys {
+		s.Name = a.pseudonym(s.Name)
+		s.Email = a.pseudonym(s.Email)
+		for _, c := range catalog.version(s.SurveyVer).Categories {
+			if c.Comment != "" {
+				s.setValue(c.Comment, redact(s.value(c.Comment), namePattern))
+			}
+		}
+	}
+}
+
+// pseudonym returns the salted hash standing for v, such as "anon-3f2a9c01b4e7",
+// or "" if v is blank or there is no salt. Case and surrounding space are
+// ignored, so that "Ann@Example.com " and "ann@example.com" are one learner.
+func (a Anonymiser) pseudonym(v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "" || a.Salt == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(a.Salt))
+	mac.Write([]byte(v))
+	return fmt.Sprintf("anon-%x", mac.Sum(nil)[:6])
+}
+
+// redact returns comment with emails, phone numbers and, if names is not
+// nil, the words it matches replaced by redaction markers.
+func redact(comment string, names *regexp.Regexp) string {
+	comment = emailPattern.ReplaceAllString(comment, redactedEmail)
+	comment = phonePattern.ReplaceAllStringFunc(comment, redactPhone)
+	if names != nil {
+		comment = redactWords(comment, names)
+	}
+	return comment
+}
+
+// redactWords returns s with the matches of re that are whole words
+// replaced by redactedName. Unlike \b, which only knows ASCII, this treats
+// any letter or digit as part of a word, so that "José" is matched whole.
+func redactWords(s string, re *regexp.Regexp) string {
+	var buf strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		before, _ := utf8.DecodeLastRuneInString(s[:m[0]])
+		after, _ := utf8.DecodeRuneInString(s[m[1]:])
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+		buf.WriteString(s[last:m[0]])
+		buf.WriteString(redactedName)
+		last = m[1]
+	}
+	buf.WriteString(s[last:])
+	return buf.String()
+}
+
+// isWordRune reports whether r is part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// redactPhone returns the redaction marker for a candidate phone number
+// matched by phonePattern, or the match itself if it has too few or too many
+// digits for a phone number or is a date.
+func redactPhone(match string) string {
+	digits := 0
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits < 7 || digits > 15 {
+		return match
+	}
+	if _, err := parseDate(match); err == nil {
+		return match
+	}
+	return redactedPhone
+}
+
+// nameWords returns names, the words they are made of and the parts of
+// those, such as "Ann-Marie", "Ann", "Marie" and "Lee" for "Ann-Marie Lee".
+// Initials are left out, since they would match too much else.
+func nameWords(names []string) []string {
+	words := append([]string{}, names...)
+	add := func(w string) {
+		w = strings.TrimFunc(w, func(r rune) bool { return !isWordRune(r) })
+		if utf8.RuneCountInString(w) > 1 {
+			words = append(words, w)
+		}
+	}
+	for _, name := range names {
+		for _, w := range strings.Fields(name) {
+			add(w)
+			for _, part := range strings.FieldsFunc(w, func(r rune) bool { return !isWordRune(r) }) {
+				add(part)
+			}
+		}
+	}
+	return words
+}
+
+// wordsPattern returns a regular expression matching any of words, ignoring
+// case, or nil if there are none. Longer words are tried first, so that a
+// full name is redacted whole rather than in part.
+func wordsPattern(words []string) *regexp.Regexp {
+	seen := make(map[string]bool)
+	var quoted []string
+	for _, w := range words {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w == "" || seen[w] {
+			continue
+		}
+		seen[w] = true
+		quoted = append(quoted, regexp.QuoteMeta(w))
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return regexp.MustCompile(`(?i)(?:` + strings.Join(quoted, "|") + `)`)
+}
+
+// readNames reads the names to redact from comments from the named file,
+// one per line. Blank lines and lines starting with "#" are ignored.
+func readNames(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, sc.Err()
+}
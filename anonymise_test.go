@@ -0,0 +1,87 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	names := wordsPattern(nameWords([]string{"John Smith", "José Núñez", "Ann-Marie O'Brien", "A. Li"}))
+	tests := []struct {
+		comment string
+		names   *regexp.Regexp
+		want    string
+	}{
+		// Emails and phone numbers.
+		{"Mail ann.lee+rh124@example.co.uk.", nil, "Mail [email]."},
+		{"Reach me at J_Doe@mail-server.example.com", nil, "Reach me at [email]"},
+		{"Call 555-123-4567 or (555) 123 4567", nil, "Call [phone] or [phone]"},
+		{"From abroad: +44 20 7946 0958.", nil, "From abroad: [phone]."},
+		{"Ext 555.123.4567", nil, "Ext [phone]"},
+
+		// Dates, short numbers and long ones are left alone.
+		{"Class of 2016-09-01 to 2016-09-05", nil, "Class of 2016-09-01 to 2016-09-05"},
+		{"Started 09/01/2016, room 101, 5/5", nil, "Started 09/01/2016, room 101, 5/5"},
+		{"Lab 12 34 took 2 hours", nil, "Lab 12 34 took 2 hours"},
+		{"Order 1234567890123456789", nil, "Order 1234567890123456789"},
+
+		// Whole names and their parts, ignoring case, as whole words.
+		{"Thanks John Smith!", names, "Thanks [name]!"},
+		{"thanks JOHN, and smith too", names, "thanks [name], and [name] too"},
+		{"Johnson and Smithers were fine", names, "Johnson and Smithers were fine"},
+		{"José rocks, not Joséphine", names, "[name] rocks, not Joséphine"},
+		{"NÚÑEZ was great", names, "[name] was great"},
+		{"Ann-Marie and o'brien helped", names, "[name] and [name] helped"},
+		{"Marie helped", names, "[name] helped"},
+		{"A lab on Linux", names, "A lab on Linux"},
+		{"Ask Li", names, "Ask [name]"},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.comment, tt.names); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.comment, got, tt.want)
+		}
+	}
+}
+
+func TestPseudonym(t *testing.T) {
+	a := Anonymiser{Salt: "pepper"}
+	p := a.pseudonym("ann@example.com")
+	if !regexp.MustCompile(`^anon-[0-9a-f]{12}$`).MatchString(p) {
+		t.Errorf("pseudonym = %q, want anon- and 12 hex digits", p)
+	}
+	if q := a.pseudonym(" Ann@Example.COM "); q != p {
+		t.Errorf("pseudonym ignoring case and space = %q, want %q", q, p)
+	}
+	if q := (Anonymiser{Salt: "pepper"}).pseudonym("ann@example.com"); q != p {
+		t.Errorf("pseudonym with the same salt = %q, want %q", q, p)
+	}
+	if q := (Anonymiser{Salt: "salt"}).pseudonym("ann@example.com"); q == p {
+		t.Errorf("pseudonym with another salt = %q, want something else", q)
+	}
+	if q := a.pseudonym("bob@example.com"); q == p {
+		t.Errorf("pseudonym of another learner = %q, want something else", q)
+	}
+	if q := a.pseudonym("  "); q != "" {
+		t.Errorf("pseudonym of a blank value = %q, want \"\"", q)
+	}
+	if q := (Anonymiser{}).pseudonym("ann@example.com"); q != "" {
+		t.Errorf("pseudonym with no salt = %q, want \"\"", q)
+	}
+}
+
+func TestAnonymise(t *testing.T) {
+	s := &Survey{Name: "John Smith", Email: "john@example.com", Q508: "John liked Ann's labs, call 555-123-4567"}
+	Anonymiser{Salt: "pepper", Names: []string{"Ann"}}.Anonymise([]*Survey{s})
+	if s.Name == "" || s.Name == "John Smith" || s.Email == "" || s.Email == "john@example.com" {
+		t.Errorf("name and email = %q, %q, want pseudonyms", s.Name, s.Email)
+	}
+	if want := "[name] liked [name]'s labs, call [phone]"; s.Q508 != want {
+		t.Errorf("comment = %q, want %q", s.Q508, want)
+	}
+
+	s = &Survey{Name: "John Smith", Email: "john@example.com"}
+	Anonymiser{}.Anonymise([]*Survey{s})
+	if s.Name != "" || s.Email != "" {
+		t.Errorf("name and email with no salt = %q, %q, want them dropped", s.Name, s.Email)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Answer is an optional numeric answer to a rated survey question. The zero
+// value represents a question that was left blank or answered N/A.
+type Answer struct {
+	Value int
+	Valid bool // Valid is true if the learner gave a numeric answer.
+}
+
+// UnmarshalText sets a from a survey answer such as "4", "N/A" or "".
+func (a *Answer) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	switch strings.ToUpper(s) {
+	case "", "N/A", "NA":
+		*a = Answer{}
+		return nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid answer %q", s)
+	}
+	*a = Answer{Value: v, Valid: true}
+	return nil
+}
+
+// MarshalText encodes a as its numeric answer, or as "" if it is unanswered.
+func (a Answer) MarshalText() ([]byte, error) {
+	if !a.Valid {
+		return []byte{}, nil
+	}
+	return []byte(strconv.Itoa(a.Value)), nil
+}
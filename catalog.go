@@ -0,0 +1,617 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// catalogEnv is the environment variable naming a question catalog file to
+// use instead of the built-in one.
+const catalogEnv = "DRIVING_CATALOG"
+
+// A Catalog describes the questions of each version of the survey: their
+// wording, category and scale, which are reverse-scored, and which hold
+// comments. Surveys are matched to a version by their survey_ver field.
+type Catalog struct {
+	// Default is the version used for surveys whose survey_ver is blank or
+	// not in Versions.
+	Default  string                     `json:"default"`
+	Versions map[string]*CatalogVersion `json:"versions"`
+}
+
+// A CatalogVersion is the set of questions asked in one survey version.
+type CatalogVersion struct {
+	Categories []CatalogCategory `json:"categories"`
+
+	// YesNo lists the Yes/No questions reported under Readiness & Impact.
+	// Only their ID, text, key and modalities are used.
+	YesNo []Question `json:"yes_no,omitempty"`
+}
+
+// A CatalogCategory lists the rated questions of a category, and the
+// question holding the learners' comments on it.
+type CatalogCategory struct {
+	Name      string     `json:"name"` // One of catalogCategories.
+	Comment   string     `json:"comment,omitempty"`
+	Questions []Question `json:"questions"`
+
+	// Modalities lists the class modalities the category applies to, such
+	// as "VT" for virtual training, matched case-insensitively. If it is
+	// empty the category applies to every class. Surveys with no modality
+	// are scored in every category.
+	Modalities []string `json:"modalities,omitempty"`
+}
+
+// catalogCategories are the category names a catalog may use, in report
+// order.
+var catalogCategories = []string{"curriculum", "instructor", "environment", "facility", "overall"}
+
+// catalog is the question catalog in use.
+var catalog = mustParseCatalog(defaultCatalog)
+
+// ParseCatalog parses and checks a catalog in JSON form.
+func ParseCatalog(b []byte) (*Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// mustParseCatalog is like ParseCatalog but panics if the catalog is
+// invalid. It is used for the built-in catalog.
+func mustParseCatalog(s string) *Catalog {
+	c, err := ParseCatalog([]byte(s))
+	if err != nil {
+		panic("built-in catalog: " + err.Error())
+	}
+	return c
+}
+
+// LoadCatalog reads a catalog from the named file.
+func LoadCatalog(filename string) (*Catalog, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseCatalog(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return c, nil
+}
+
+// check returns an error describing the first problem with c, if any. It
+// also rewrites question IDs in their canonical form, so that "Q2-07" in a
+// catalog file means Q207.
+func (c *Catalog) check() error {
+	if _, ok := c.Versions[c.Default]; !ok {
+		return fmt.Errorf("default version %q is not defined", c.Default)
+	}
+	for _, ver := range c.versionNames() {
+		v := c.Versions[ver]
+		if v == nil {
+			return fmt.Errorf("version %q: no categories", ver)
+		}
+		seenCategory := make(map[string]bool)
+		seenQuestion := make(map[string]bool)
+		nps := 0
+		for i := range v.Categories {
+			cat := &v.Categories[i]
+			if !isCatalogCategory(cat.Name) {
+				return fmt.Errorf("version %q: unknown category %q (supported: %s)", ver, cat.Name, strings.Join(catalogCategories, ", "))
+			}
+			if seenCategory[cat.Name] {
+				return fmt.Errorf("version %q: category %q is defined twice", ver, cat.Name)
+			}
+			seenCategory[cat.Name] = true
+			for _, m := range cat.Modalities {
+				if strings.TrimSpace(m) == "" {
+					return fmt.Errorf("version %q: category %q: empty modality", ver, cat.Name)
+				}
+			}
+			if cat.Comment != "" {
+				if !isQuestionKey(normalizeKey(cat.Comment)) {
+					return fmt.Errorf("version %q: category %q: invalid comment question ID %q", ver, cat.Name, cat.Comment)
+				}
+				cat.Comment = questionID(cat.Comment)
+			}
+			for j := range cat.Questions {
+				q := &cat.Questions[j]
+				if !isQuestionKey(normalizeKey(q.ID)) {
+					return fmt.Errorf("version %q: invalid question ID %q", ver, q.ID)
+				}
+				q.ID = questionID(q.ID)
+				if q.Key != "" {
+					if !isQuestionKey(normalizeKey(q.Key)) {
+						return fmt.Errorf("version %q: question %s: invalid key %q", ver, q.ID, q.Key)
+					}
+					q.Key = questionID(q.Key)
+				}
+				switch {
+				case seenQuestion[q.ID]:
+					return fmt.Errorf("version %q: question %s is defined twice", ver, q.ID)
+				case q.Max < 2 || q.Max > maxScale:
+					return fmt.Errorf("version %q: question %s: scale must be from 2 to %d", ver, q.ID, maxScale)
+				case q.NPS && q.Max != 10:
+					return fmt.Errorf("version %q: question %s: the NPS question must have a scale of 10", ver, q.ID)
+				case len(q.Modalities) > 0:
+					return fmt.Errorf("version %q: question %s: modalities belong to its category", ver, q.ID)
+				}
+				seenQuestion[q.ID] = true
+				if q.NPS {
+					nps++
+				}
+			}
+		}
+		if nps > 1 {
+			return fmt.Errorf("version %q: more than one NPS question", ver)
+		}
+		for i := range v.YesNo {
+			q := &v.YesNo[i]
+			if !isQuestionKey(normalizeKey(q.ID)) || (q.Key != "" && !isQuestionKey(normalizeKey(q.Key))) {
+				return fmt.Errorf("version %q: invalid Yes/No question ID or key %q", ver, q.ID)
+			}
+			q.ID = questionID(q.ID)
+			if q.Key != "" {
+				q.Key = questionID(q.Key)
+			}
+			if seenQuestion[q.ID] {
+				return fmt.Errorf("version %q: question %s is defined twice", ver, q.ID)
+			}
+			seenQuestion[q.ID] = true
+		}
+		seenKey := make(map[string]string)
+		for _, q := range v.allQuestions() {
+			if other, ok := seenKey[q.key()]; ok {
+				return fmt.Errorf("version %q: questions %s and %s have the same key %s", ver, other, q.ID, q.key())
+			}
+			seenKey[q.key()] = q.ID
+		}
+	}
+	return nil
+}
+
+// isCatalogCategory reports whether name is one of catalogCategories.
+func isCatalogCategory(name string) bool {
+	for _, c := range catalogCategories {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// versionNames returns the names of c's versions in sorted order.
+func (c *Catalog) versionNames() []string {
+	return sortedKeys(c.Versions)
+}
+
+// versionName returns the name of the catalog version used for surveys
+// whose survey_ver is ver: ver itself if the catalog has such a version, or
+// else the default version.
+func (c *Catalog) versionName(ver string) string {
+	ver = strings.TrimSpace(ver)
+	if _, ok := c.Versions[ver]; ok {
+		return ver
+	}
+	return c.Default
+}
+
+// version returns the catalog version used for surveys whose survey_ver is
+// ver, as named by versionName.
+func (c *Catalog) version(ver string) *CatalogVersion {
+	return c.Versions[c.versionName(ver)]
+}
+
+// canonical returns the canonical form of q, which answers to q in every
+// version are normalised to: its definition in the default version if it is
+// asked there, or else in the first version, in sorted order, that asks it.
+func (c *Catalog) canonical(q Question) Question {
+	if cq, ok := c.Versions[c.Default].question(q.ID); ok {
+		return cq
+	}
+	for _, ver := range c.versionNames() {
+		if cq, ok := c.Versions[ver].question(q.ID); ok {
+			return cq
+		}
+	}
+	return q
+}
+
+// npsQuestion returns the canonical form of the NPS question: that of the
+// default version if it has one, or else of the first version, in sorted
+// order, that does.
+func (c *Catalog) npsQuestion() (Question, bool) {
+	for _, ver := range append([]string{c.Default}, c.versionNames()...) {
+		if q, ok := c.Versions[ver].npsQuestion(); ok {
+			return c.canonical(q), true
+		}
+	}
+	return Question{}, false
+}
+
+// scoreScale returns the scale category averages are on: the largest
+// canonical scale of the questions counted towards them in any version. It
+// is 5 if there are no such questions.
+func (c *Catalog) scoreScale() int {
+	return c.categoryScale("")
+}
+
+// categoryScale returns the top of the scale scores in the named category
+// are on, or, if name is "", in any category: the largest canonical scale of
+// their scored questions, or 5 if there are none.
+func (c *Catalog) categoryScale(name string) int {
+	max := 0
+	for _, v := range c.Versions {
+		for _, cat := range v.Categories {
+			if name != "" && cat.Name != name {
+				continue
+			}
+			for _, q := range cat.scored() {
+				if cq := c.canonical(q); cq.Max > max {
+					max = cq.Max
+				}
+			}
+		}
+	}
+	if max == 0 {
+		return 5
+	}
+	return max
+}
+
+// category returns the named category of v, or an empty category if v does
+// not ask about it.
+func (v *CatalogVersion) category(name string) CatalogCategory {
+	for _, c := range v.Categories {
+		if c.Name == name {
+			return c
+		}
+	}
+	return CatalogCategory{Name: name}
+}
+
+// question returns the rated question of v with the given ID.
+func (v *CatalogVersion) question(id string) (Question, bool) {
+	for _, c := range v.Categories {
+		for _, q := range c.Questions {
+			if q.ID == id {
+				return q, true
+			}
+		}
+	}
+	return Question{}, false
+}
+
+// yesNoQuestion returns the Yes/No question of v with the given ID.
+func (v *CatalogVersion) yesNoQuestion(id string) (Question, bool) {
+	for _, q := range v.YesNo {
+		if q.ID == id {
+			return q, true
+		}
+	}
+	return Question{}, false
+}
+
+// allQuestions returns the rated and Yes/No questions of v.
+func (v *CatalogVersion) allQuestions() []Question {
+	var qs []Question
+	for _, c := range v.Categories {
+		qs = append(qs, c.Questions...)
+	}
+	return append(qs, v.YesNo...)
+}
+
+// questionByKey returns the rated question of v answered under the given
+// key in survey files.
+func (v *CatalogVersion) questionByKey(key string) (Question, bool) {
+	for _, c := range v.Categories {
+		for _, q := range c.Questions {
+			if q.key() == key {
+				return q, true
+			}
+		}
+	}
+	return Question{}, false
+}
+
+// renumbered reports whether id is the ID of a question v asks under another
+// key, so that an answer under the key id is not an answer to it.
+func (v *CatalogVersion) renumbered(id string) bool {
+	q, ok := v.question(id)
+	return ok && q.key() != id
+}
+
+// npsQuestion returns v's NPS question.
+func (v *CatalogVersion) npsQuestion() (Question, bool) {
+	for _, c := range v.Categories {
+		for _, q := range c.Questions {
+			if q.NPS {
+				return q, true
+			}
+		}
+	}
+	return Question{}, false
+}
+
+// applies reports whether c applies to surveys of s's modality.
+func (c CatalogCategory) applies(s *Survey) bool {
+	return appliesTo(c.Modalities, s)
+}
+
+// applies reports whether q, a Yes/No question, is asked in surveys of s's
+// modality.
+func (q Question) applies(s *Survey) bool {
+	return appliesTo(q.Modalities, s)
+}
+
+// appliesTo reports whether s's modality is one of modalities. Every
+// modality is if modalities is empty, and surveys with no modality match
+// any.
+func appliesTo(modalities []string, s *Survey) bool {
+	modality := strings.TrimSpace(s.Modality)
+	if len(modalities) == 0 || modality == "" {
+		return true
+	}
+	for _, m := range modalities {
+		if strings.EqualFold(strings.TrimSpace(m), modality) {
+			return true
+		}
+	}
+	return false
+}
+
+// scored returns the questions of c that count towards its average, which
+// are all but the NPS question.
+func (c CatalogCategory) scored() []Question {
+	var qs []Question
+	for _, q := range c.Questions {
+		if !q.NPS {
+			qs = append(qs, q)
+		}
+	}
+	return qs
+}
+
+// isQuestion reports whether key is the key of a rated, Yes/No or comment
+// question in any version of c.
+func (c *Catalog) isQuestion(key string) bool {
+	for _, v := range c.Versions {
+		for _, cat := range v.Categories {
+			if cat.Comment == key {
+				return true
+			}
+		}
+		for _, q := range v.allQuestions() {
+			if q.key() == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// questions returns the canonical form of the questions of the named
+// category in every version the surveys were answered in, without repeats.
+// The questions of the default version come first, in catalog order,
+// followed by those only asked in other versions, in version order. If there
+// are no surveys, the questions of the default version are returned.
+func (c *Catalog) questions(category string, surveys []*Survey) []Question {
+	used := map[string]bool{c.Default: len(surveys) == 0}
+	for _, s := range surveys {
+		used[c.versionName(s.SurveyVer)] = true
+	}
+	versions := []string{c.Default}
+	for _, ver := range c.versionNames() {
+		if ver != c.Default {
+			versions = append(versions, ver)
+		}
+	}
+
+	var qs []Question
+	seenQuestion := make(map[string]bool)
+	for _, ver := range versions {
+		if !used[ver] {
+			continue
+		}
+		for _, q := range c.Versions[ver].category(category).Questions {
+			if !seenQuestion[q.ID] {
+				seenQuestion[q.ID] = true
+				qs = append(qs, c.canonical(q))
+			}
+		}
+	}
+	return qs
+}
+
+// isYesNo reports whether id is the ID of a Yes/No question in any version
+// of c.
+func (c *Catalog) isYesNo(id string) bool {
+	for _, v := range c.Versions {
+		if _, ok := v.yesNoQuestion(id); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// yesNoQuestions returns the Yes/No questions of every version the surveys
+// were answered in, without repeats, in the order given by questions.
+func (c *Catalog) yesNoQuestions(surveys []*Survey) []Question {
+	used := map[string]bool{c.Default: len(surveys) == 0}
+	for _, s := range surveys {
+		used[c.versionName(s.SurveyVer)] = true
+	}
+	var qs []Question
+	seenQuestion := make(map[string]bool)
+	for _, ver := range append([]string{c.Default}, c.versionNames()...) {
+		if !used[ver] {
+			continue
+		}
+		for _, q := range c.Versions[ver].YesNo {
+			if !seenQuestion[q.ID] {
+				seenQuestion[q.ID] = true
+				qs = append(qs, q)
+			}
+		}
+	}
+	return qs
+}
+
+// applicable returns the surveys that the named category applies to in their
+// version of c.
+func (c *Catalog) applicable(category string, surveys []*Survey) []*Survey {
+	var applicable []*Survey
+	for _, s := range surveys {
+		if c.version(s.SurveyVer).category(category).applies(s) {
+			applicable = append(applicable, s)
+		}
+	}
+	return applicable
+}
+
+// SurveyVersions returns the number of surveys of each survey version, as
+// named by versionLabel.
+func (c *Catalog) SurveyVersions(surveys []*Survey) map[string]int {
+	versions := make(map[string]int)
+	for _, s := range surveys {
+		versions[c.versionLabel(s.SurveyVer)]++
+	}
+	return versions
+}
+
+// versionLabel names the version of surveys whose survey_ver is ver in
+// reports: the name of the catalog version they are scored against, noting
+// when ver is not in the catalog, such as "1 (scored as 2)". Surveys with
+// no survey_ver are labelled with the default version.
+func (c *Catalog) versionLabel(ver string) string {
+	ver = strings.TrimSpace(ver)
+	if ver == "" || c.isVersion(ver) {
+		return c.versionName(ver)
+	}
+	return fmt.Sprintf("%s (scored as %s)", ver, c.Default)
+}
+
+// isVersion reports whether c has a version named ver.
+func (c *Catalog) isVersion(ver string) bool {
+	_, ok := c.Versions[strings.TrimSpace(ver)]
+	return ok
+}
+
+// catalogFlag adds the -catalog flag to fs. The catalog it names must be
+// put in use with useCatalog once fs is parsed.
+func catalogFlag(fs *flag.FlagSet) *string {
+	return fs.String("catalog", os.Getenv(catalogEnv), "question catalog file (default built in, or from $"+catalogEnv+")")
+}
+
+// useCatalog puts the catalog in the named file in use, or keeps the
+// built-in catalog if filename is empty.
+func useCatalog(filename string) {
+	if filename == "" {
+		return
+	}
+	c, err := LoadCatalog(filename)
+	if err != nil {
+		log.Fatalf("[INFO] Error loading catalog: %s\n", err)
+	}
+	catalog = c
+}
+
+// runCatalog implements the catalog command, which prints the question
+// catalog in use as JSON, as a starting point for a catalog file.
+func runCatalog(args []string) {
+	fs := flag.NewFlagSet("catalog", flag.ExitOnError)
+	file := catalogFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s catalog [-catalog file]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	useCatalog(*file)
+
+	b, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		log.Fatalf("[INFO] Error rendering catalog: %s\n", err)
+	}
+	fmt.Printf("%s\n", b)
+}
+
+// defaultCatalog is the built-in catalog, describing version 2 of the
+// survey.
+const defaultCatalog = `{
+  "default": "2",
+  "versions": {
+    "2": {
+      "categories": [
+        {
+          "name": "curriculum",
+          "comment": "Q508",
+          "questions": [
+            {"id": "Q207", "text": "The student guide was accurate and had the right amount of detail", "scale": 5},
+            {"id": "Q208", "text": "The course had a logical structure and covered relevant subject matter", "scale": 5},
+            {"id": "Q209", "text": "The labs adequately reinforced the topics discussed in class", "scale": 5},
+            {"id": "Q210", "text": "The course allowed sufficient time to adequately cover the material", "scale": 5}
+          ]
+        },
+        {
+          "name": "instructor",
+          "comment": "Q318",
+          "questions": [
+            {"id": "Q306", "text": "The instructor demonstrated expertise in the topics taught", "scale": 5},
+            {"id": "Q307", "text": "The instructor showed evidence of strong preparation", "scale": 5},
+            {"id": "Q308", "text": "The instructor made concepts and tasks clear", "scale": 5},
+            {"id": "Q320", "text": "The instructor effectively managed classroom interaction and student participation", "scale": 5}
+          ]
+        },
+        {
+          "name": "environment",
+          "comment": "Q1907",
+          "modalities": ["VT"],
+          "questions": [
+            {"id": "Q1002", "text": "Pre-class support was effective, responsive and accessible", "scale": 5},
+            {"id": "Q1003", "text": "The performance of the audio conferencing system was adequate", "scale": 5},
+            {"id": "Q1004", "text": "The performance of the web conferencing system was adequate", "scale": 5},
+            {"id": "Q1005", "text": "The performance of lab exercises was adequate", "scale": 5}
+          ]
+        },
+        {
+          "name": "facility",
+          "comment": "Q610",
+          "modalities": ["ILT", "Onsite"],
+          "questions": [
+            {"id": "Q611", "text": "The computers and network were sufficient for the class", "scale": 5},
+            {"id": "Q609", "text": "The room and facility were comfortable", "scale": 5},
+            {"id": "Q612", "text": "The facility staff were hospitable", "scale": 5}
+          ]
+        },
+        {
+          "name": "overall",
+          "comment": "Q403",
+          "questions": [
+            {"id": "Q311", "text": "Please tell us your overall rating of this training event", "scale": 5},
+            {"id": "Q410", "text": "How likely would you be to recommend Red Hat Training to a friend or colleague in need of similar training?", "scale": 10, "nps": true}
+          ]
+        }
+      ],
+      "yes_no": [
+        {"id": "Q109", "text": "Did you meet the course prerequisites for this class?"},
+        {"id": "Q105", "text": "Did you complete Red Hat's online skills assessment before enrolling in this class?"},
+        {"id": "Q111", "text": "Are you better prepared now than before class to maximize the value of your Red Hat products?"},
+        {"id": "Q112", "text": "Are you more likely now than before class to explore the adoption of new Red Hat technologies?"},
+        {"id": "Q113", "text": "Are your IT projects involving Red Hat technologies more likely to succeed after completing this training?"},
+        {"id": "Q101", "text": ""},
+        {"id": "Q1901", "text": "I tested my connection and systems prior to the start of the course", "modalities": ["VT"]}
+      ]
+    }
+  }
+}
+`
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSurveyVersions(t *testing.T) {
+	surveys := []*Survey{
+		{SurveyVer: "2"},
+		{SurveyVer: " 2 "},
+		{},
+		{SurveyVer: "1"},
+	}
+	want := map[string]int{"2": 3, "1 (scored as 2)": 1}
+	if got := catalog.SurveyVersions(surveys); !reflect.DeepEqual(got, want) {
+		t.Errorf("SurveyVersions = %v, want %v", got, want)
+	}
+}
+
+func TestParseCatalog(t *testing.T) {
+	c, err := ParseCatalog([]byte(`{
+  "default": "2",
+  "versions": {
+    "2": {
+      "categories": [
+        {"name": "curriculum", "comment": "q5-08", "questions": [
+          {"id": "q2-07", "text": "Accurate student guide", "scale": 5},
+          {"id": "Q208", "key": "q2-01", "text": "Logical structure", "scale": 7, "reverse": true}
+        ]},
+        {"name": "overall", "questions": [{"id": "Q410", "text": "Recommend", "scale": 10, "nps": true}]}
+      ],
+      "yes_no": [{"id": "q1-09", "text": "Met prerequisites", "modalities": ["VT"]}]
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := c.version("")
+	if q, ok := v.question("Q207"); !ok || q.key() != "Q207" {
+		t.Errorf("question Q207 = %+v, %v", q, ok)
+	}
+	if q, ok := v.questionByKey("Q201"); !ok || q.ID != "Q208" || q.Max != 7 || !q.Reverse {
+		t.Errorf("question under key Q201 = %+v, %v", q, ok)
+	}
+	if q, ok := v.yesNoQuestion("Q109"); !ok || len(q.Modalities) != 1 {
+		t.Errorf("Yes/No question Q109 = %+v, %v", q, ok)
+	}
+	if got := v.category("curriculum").Comment; got != "Q508" {
+		t.Errorf("curriculum comment question = %q, want Q508", got)
+	}
+	if q, ok := c.npsQuestion(); !ok || q.ID != "Q410" {
+		t.Errorf("NPS question = %+v, %v", q, ok)
+	}
+}
+
+func TestParseCatalogErrors(t *testing.T) {
+	// version returns a catalog whose only version, the default, is given.
+	version := func(v string) string {
+		return `{"default": "2", "versions": {"2": ` + v + `}}`
+	}
+	tests := []struct {
+		catalog string
+		err     string // Part of the error expected.
+	}{
+		{`{"default": "2", "versions": {`, "unexpected end"},
+		{`{"default": "2", "versions": {"1": {"categories": []}}}`, `default version "2" is not defined`},
+		{`{"versions": {"2": {"categories": []}}}`, `default version "" is not defined`},
+		{`{"default": "2", "versions": {"2": {"categories": []}, "3": null}}`, `version "3": no categories`},
+		{version(`{"categories": [{"name": "trainer", "questions": []}]}`), `unknown category "trainer"`},
+		{version(`{"categories": [{"name": "overall", "questions": []}, {"name": "overall", "questions": []}]}`), `category "overall" is defined twice`},
+		{version(`{"categories": [{"name": "overall", "modalities": [" "], "questions": []}]}`), "empty modality"},
+		{version(`{"categories": [{"name": "overall", "comment": "notes", "questions": []}]}`), `invalid comment question ID "notes"`},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "311", "scale": 5}]}]}`), `invalid question ID "311"`},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q311", "key": "overall", "scale": 5}]}]}`), `invalid key "overall"`},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q311", "scale": 5}, {"id": "q3-11", "scale": 5}]}]}`), "question Q311 is defined twice"},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q311"}]}]}`), "scale must be from 2"},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q311", "scale": 1}]}]}`), "scale must be from 2"},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q311", "scale": 11}]}]}`), "scale must be from 2"},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q410", "scale": 5, "nps": true}]}]}`), "must have a scale of 10"},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q410", "scale": 10, "nps": true}, {"id": "Q411", "scale": 10, "nps": true}]}]}`), "more than one NPS question"},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q311", "scale": 5, "modalities": ["VT"]}]}]}`), "modalities belong to its category"},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q311", "scale": 5}, {"id": "Q312", "key": "Q311", "scale": 5}]}]}`), "questions Q311 and Q312 have the same key Q311"},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q311", "scale": 5}]}], "yes_no": [{"id": "Q109", "key": "Q311"}]}`), "questions Q311 and Q109 have the same key Q311"},
+		{version(`{"categories": [{"name": "overall", "questions": [{"id": "Q311", "scale": 5}]}], "yes_no": [{"id": "Q311"}]}`), "question Q311 is defined twice"},
+		{version(`{"categories": [], "yes_no": [{"id": "prereqs"}]}`), `invalid Yes/No question ID or key "prereqs"`},
+	}
+	for _, tt := range tests {
+		_, err := ParseCatalog([]byte(tt.catalog))
+		if err == nil {
+			t.Errorf("ParseCatalog(%s) succeeded, want an error containing %q", tt.catalog, tt.err)
+		} else if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParseCatalog(%s) = %q, want an error containing %q", tt.catalog, err, tt.err)
+		}
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	if _, err := LoadCatalog(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("LoadCatalog of a missing file succeeded")
+	}
+	name := filepath.Join(t.TempDir(), "catalog.json")
+	if err := os.WriteFile(name, []byte(`{"default": "2", "versions": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCatalog(name); err == nil || !strings.Contains(err.Error(), name) {
+		t.Errorf("LoadCatalog of an invalid catalog = %v, want an error naming the file", err)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"strings"
+)
+
+// Colors used in charts.
+var (
+	colorBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	colorText       = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	colorAxis       = color.RGBA{0x99, 0x99, 0x99, 0xff}
+	colorGrid       = color.RGBA{0xe5, 0xe5, 0xe5, 0xff}
+	colorBar        = color.RGBA{0x3b, 0x6e, 0xa8, 0xff}
+	colorWhisker    = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	colorPromoter   = color.RGBA{0x2e, 0x9e, 0x4f, 0xff}
+	colorPassive    = color.RGBA{0xbb, 0xbb, 0xbb, 0xff}
+	colorDetractor  = color.RGBA{0xd0, 0x3b, 0x3b, 0xff}
+	colorYes        = color.RGBA{0x2e, 0x9e, 0x4f, 0xff}
+	colorNo         = color.RGBA{0xd0, 0x3b, 0x3b, 0xff}
+	colorBlank      = color.RGBA{0xbb, 0xbb, 0xbb, 0xff}
+)
+
+// A chartPart is one segment of a stacked bar of counts.
+type chartPart struct {
+	label string
+	n     int
+	col   color.RGBA
+}
+
+// yesNoParts returns the segments of a stacked bar of the answers to a
+// Yes/No question.
+func yesNoParts(st YesNoStats) []chartPart {
+	return []chartPart{
+		{"yes", st.Yes, colorYes},
+		{"no", st.No, colorNo},
+		{"blank", st.Blank, colorBlank},
+	}
+}
+
+// answerLegend returns the scale the legend of a chart of the answers to qs
+// shows: the most common scale of the questions other than the NPS question,
+// or of the NPS question if there are no others. It also returns a note
+// naming the questions on other scales, such as "(Q410: 1-10, same
+// colors)", or "" if there are none.
+func answerLegend(qs []QuestionStats) (scale int, note string) {
+	nps, _ := catalog.npsQuestion()
+	counts := make(map[int]int)
+	for _, q := range qs {
+		if q.ID != nps.ID {
+			counts[len(q.Histogram)]++
+		}
+	}
+	if len(counts) == 0 {
+		for _, q := range qs {
+			counts[len(q.Histogram)]++
+		}
+	}
+	for n, count := range counts {
+		if count > counts[scale] || (count == counts[scale] && n > scale) {
+			scale = n
+		}
+	}
+
+	others := make(map[int][]string)
+	var scales []int
+	for _, q := range qs {
+		n := len(q.Histogram)
+		if n == scale {
+			continue
+		}
+		if others[n] == nil {
+			scales = append(scales, n)
+		}
+		others[n] = append(others[n], q.ID)
+	}
+	if len(scales) == 0 {
+		return scale, ""
+	}
+	sort.Ints(scales)
+	var parts []string
+	for _, n := range scales {
+		parts = append(parts, fmt.Sprintf("%s: 1-%d", strings.Join(others[n], ", "), n))
+	}
+	return scale, "(" + strings.Join(parts, " / ") + ", same colors)"
+}
+
+// seriesColors are the colors of the lines in line charts, in order.
+var seriesColors = []color.RGBA{
+	{0x3b, 0x6e, 0xa8, 0xff},
+	{0xe0, 0x8a, 0x1e, 0xff},
+	{0x2e, 0x9e, 0x4f, 0xff},
+	{0x8e, 0x44, 0xad, 0xff},
+	{0xd0, 0x3b, 0x3b, 0xff},
+}
+
+// scaleColor returns the color of answer v on a scale from 1 to max, running
+// from red for 1 through yellow to green for max.
+func scaleColor(v, max int) color.RGBA {
+	if max <= 1 {
+		return colorPromoter
+	}
+	f := float64(v-1) / float64(max-1)
+	lerp := func(a, b uint8, t float64) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t)
+	}
+	red := color.RGBA{0xd0, 0x3b, 0x3b, 0xff}
+	yellow := color.RGBA{0xf2, 0xc7, 0x2e, 0xff}
+	green := color.RGBA{0x2e, 0x9e, 0x4f, 0xff}
+	from, to, t := red, yellow, f*2
+	if f > 0.5 {
+		from, to, t = yellow, green, (f-0.5)*2
+	}
+	return color.RGBA{lerp(from.R, to.R, t), lerp(from.G, to.G, t), lerp(from.B, to.B, t), 0xff}
+}
+
+// A canvas is an image that charts are drawn on.
+type canvas struct {
+	*image.RGBA
+}
+
+// newCanvas returns a blank canvas of the given size.
+func newCanvas(width, height int) canvas {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{colorBackground}, image.Point{}, draw.Src)
+	return canvas{img}
+}
+
+// fill fills the rectangle from (x0, y0) up to but not including (x1, y1).
+func (c canvas) fill(x0, y0, x1, y1 int, col color.Color) {
+	draw.Draw(c, image.Rect(x0, y0, x1, y1), &image.Uniform{col}, image.Point{}, draw.Src)
+}
+
+// line draws a one pixel wide line from (x0, y0) to (x1, y1).
+func (c canvas) line(x0, y0, x1, y1 int, col color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		c.Set(x0, y0, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x0 += sx
+		} else {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// thickLine draws a line two pixels wide.
+func (c canvas) thickLine(x0, y0, x1, y1 int, col color.Color) {
+	c.line(x0, y0, x1, y1, col)
+	c.line(x0, y0+1, x1, y1+1, col)
+}
+
+// text draws s with its top left corner at (x, y), using the built-in font
+// magnified scale times. Letters are drawn in upper case, and characters the
+// font lacks as "?". It returns the x coordinate just after the text.
+func (c canvas) text(x, y int, s string, scale int, col color.Color) int {
+	for _, r := range strings.ToUpper(s) {
+		g, ok := font[r]
+		if !ok {
+			g = font['?']
+		}
+		for row, bits := range g {
+			for bit := 0; bit < glyphWidth; bit++ {
+				if bits&(1<<uint(glyphWidth-1-bit)) != 0 {
+					px, py := x+bit*scale, y+row*scale
+					c.fill(px, py, px+scale, py+scale, col)
+				}
+			}
+		}
+		x += (glyphWidth + 1) * scale
+	}
+	return x
+}
+
+// textWidth returns the width of s when drawn by text.
+func textWidth(s string, scale int) int {
+	return len([]rune(s)) * (glyphWidth + 1) * scale
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Glyph dimensions of the built-in font, in pixels.
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+// font is a minimal 5x7 bitmap font. Each glyph is a row of bits per line,
+// most significant (of five) bit leftmost.
+var font = map[rune][glyphHeight]uint8{
+	' ':  {},
+	'0':  {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	'1':  {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2':  {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	'3':  {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4':  {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	'5':  {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6':  {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	'7':  {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8':  {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	'9':  {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	'A':  {0x0e, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'B':  {0x1e, 0x11, 0x11, 0x1e, 0x11, 0x11, 0x1e},
+	'C':  {0x0e, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0e},
+	'D':  {0x1c, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1c},
+	'E':  {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x1f},
+	'F':  {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x10},
+	'G':  {0x0e, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0f},
+	'H':  {0x11, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'I':  {0x0e, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'J':  {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0c},
+	'K':  {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L':  {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1f},
+	'M':  {0x11, 0x1b, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N':  {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O':  {0x0e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},
+	'P':  {0x1e, 0x11, 0x11, 0x1e, 0x10, 0x10, 0x10},
+	'Q':  {0x0e, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0d},
+	'R':  {0x1e, 0x11, 0x11, 0x1e, 0x14, 0x12, 0x11},
+	'S':  {0x0f, 0x10, 0x10, 0x0e, 0x01, 0x01, 0x1e},
+	'T':  {0x1f, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},
+	'V':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x0a, 0x04},
+	'W':  {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0a},
+	'X':  {0x11, 0x11, 0x0a, 0x04, 0x0a, 0x11, 0x11},
+	'Y':  {0x11, 0x11, 0x11, 0x0a, 0x04, 0x04, 0x04},
+	'Z':  {0x1f, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1f},
+	'.':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x0c},
+	',':  {0x00, 0x00, 0x00, 0x00, 0x0c, 0x04, 0x08},
+	'-':  {0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00},
+	'+':  {0x00, 0x04, 0x04, 0x1f, 0x04, 0x04, 0x00},
+	'%':  {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+	'/':  {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	':':  {0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x0c, 0x00},
+	'=':  {0x00, 0x00, 0x1f, 0x00, 0x1f, 0x00, 0x00},
+	'(':  {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')':  {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+	'[':  {0x0e, 0x08, 0x08, 0x08, 0x08, 0x08, 0x0e},
+	']':  {0x0e, 0x02, 0x02, 0x02, 0x02, 0x02, 0x0e},
+	'_':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1f},
+	'&':  {0x0c, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0d},
+	'\'': {0x04, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00},
+	'?':  {0x0e, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+}
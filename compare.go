@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// A ComparisonRow compares the scores of one category or question between
+// two sets of surveys, A and B.
+type ComparisonRow struct {
+	Category string `json:"category"`
+	Question string `json:"question,omitempty"` // Empty for the category itself.
+
+	MeanA  *float64 `json:"mean_a"` // nil if CountA is 0.
+	CountA int      `json:"count_a"`
+	MeanB  *float64 `json:"mean_b"` // nil if CountB is 0.
+	CountB int      `json:"count_b"`
+
+	// Delta is MeanB - MeanA and DeltaPct the same as a percentage of
+	// MeanA. Both are nil if either mean is.
+	Delta    *float64 `json:"delta"`
+	DeltaPct *float64 `json:"delta_pct"`
+
+	// Two-sided p-values for a difference between A and B, nil if there
+	// are too few answers for the test.
+	WelchP       *float64 `json:"welch_p"`
+	MannWhitneyP *float64 `json:"mann_whitney_p"`
+}
+
+// A Comparison sets the scores of two sets of surveys, A and B, side by side.
+type Comparison struct {
+	A          string          `json:"a"` // Description of A, such as its file name.
+	B          string          `json:"b"`
+	ResponsesA int             `json:"responses_a"`
+	ResponsesB int             `json:"responses_b"`
+	Rows       []ComparisonRow `json:"rows"`
+
+	// NPS of A and B, nil if nobody in them answered, with the change and
+	// the p-value of npsZTest.
+	NPSA     *float64 `json:"nps_a"`
+	NPSB     *float64 `json:"nps_b"`
+	NPSDelta *float64 `json:"nps_delta"`
+	NPSP     *float64 `json:"nps_p"`
+}
+
+// NewComparison compares the surveys in a, described as labelA, with those
+// in b, described as labelB, category by category and question by question.
+// Categories that apply to none of the surveys are left out.
+func NewComparison(labelA string, a []*Survey, labelB string, b []*Survey) Comparison {
+	cmp := Comparison{A: labelA, B: labelB, ResponsesA: len(a), ResponsesB: len(b)}
+	for _, name := range catalogCategories {
+		appA := catalog.applicable(name, a)
+		appB := catalog.applicable(name, b)
+		both := append(append([]*Survey{}, appA...), appB...)
+		if len(both) == 0 && len(a)+len(b) > 0 {
+			continue
+		}
+		qs := catalog.questions(name, both)
+		if len(qs) == 0 {
+			continue
+		}
+		cmp.Rows = append(cmp.Rows, newComparisonRow(name, "", categorySample(name, a), categorySample(name, b)))
+		for _, q := range qs {
+			cmp.Rows = append(cmp.Rows, newComparisonRow(name, q.ID, questionSample(q, appA), questionSample(q, appB)))
+		}
+	}
+
+	ra, rb := NewReport(a, false), NewReport(b, false)
+	cmp.NPSA = optional(ra.NPS, ra.NPSAvailable)
+	cmp.NPSB = optional(rb.NPS, rb.NPSAvailable)
+	cmp.NPSDelta = optional(rb.NPS-ra.NPS, ra.NPSAvailable && rb.NPSAvailable)
+	cmp.NPSP = optional(npsZTest(ra.Promoters, ra.Passives, ra.Detractors, rb.Promoters, rb.Passives, rb.Detractors))
+	return cmp
+}
+
+// newComparisonRow returns the row comparing samples x and y.
+func newComparisonRow(category, question string, x, y sample) ComparisonRow {
+	row := ComparisonRow{
+		Category: category,
+		Question: question,
+		MeanA:    optional(x.mean(), len(x) > 0),
+		CountA:   len(x),
+		MeanB:    optional(y.mean(), len(y) > 0),
+		CountB:   len(y),
+	}
+	if len(x) > 0 && len(y) > 0 {
+		row.Delta = optional(y.mean()-x.mean(), true)
+		row.DeltaPct = optional((y.mean()-x.mean())/x.mean()*100, x.mean() != 0)
+	}
+	row.WelchP = optional(welchTTest(x, y))
+	row.MannWhitneyP = optional(mannWhitneyU(x, y))
+	return row
+}
+
+// writeComparison writes cmp as an aligned table, with A and B side by side.
+func writeComparison(w io.Writer, cmp Comparison) {
+	fmt.Fprintf(w, "A: %s (%d responses)\n", cmp.A, cmp.ResponsesA)
+	fmt.Fprintf(w, "B: %s (%d responses)\n\n", cmp.B, cmp.ResponsesB)
+	fmt.Fprintf(w, "%-11s %12s %12s %7s %8s %7s %12s\n", "", "A", "B", "Delta", "Delta %", "Welch p", "Mann-Whitney")
+	for _, row := range cmp.Rows {
+		label := strings.ToUpper(row.Category[:1]) + row.Category[1:]
+		if row.Question != "" {
+			label = "  " + row.Question
+		}
+		writeLine(w, "%-11s %12s %12s %7s %8s %7s %12s", label,
+			formatCompared(row.MeanA, row.CountA), formatCompared(row.MeanB, row.CountB),
+			formatOptional("%+.2f", row.Delta), formatOptional("%+.1f%%", row.DeltaPct),
+			formatOptional("%.3f", row.WelchP), formatOptional("%.3f", row.MannWhitneyP))
+	}
+	writeLine(w, "%-11s %12s %12s %7s %8s %7s %12s", "NPS",
+		formatOptional("%.2f", cmp.NPSA), formatOptional("%.2f", cmp.NPSB),
+		formatOptional("%+.2f", cmp.NPSDelta), "", formatOptional("%.3f", cmp.NPSP), "")
+}
+
+// formatCompared formats a mean over count values, such as "4.20 (n=12)".
+func formatCompared(mean *float64, count int) string {
+	return fmt.Sprintf("%s (n=%d)", formatOptional("%.2f", mean), count)
+}
+
+// formatOptional formats v with format, or returns "n/a" if v is nil.
+func formatOptional(format string, v *float64) string {
+	if v == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf(format, *v)
+}
+
+// runCompare implements the compare command, which compares the surveys in
+// two files side by side, or two segments of the same surveys selected by
+// the -a and -b filters.
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	format := fs.String("f", "text", "output format (text, json)")
+	strict := fs.Bool("strict", false, "fail on the first malformed record instead of skipping it")
+	catalogFile := catalogFlag(fs)
+	tz := tzFlag(fs)
+	var filters filterFlag
+	fs.Var(&filters, "filter", "only compare surveys matching `expr` (repeatable)")
+	exprA := fs.String("a", "", "compare the surveys matching `expr` ...")
+	exprB := fs.String("b", "", "... with those matching `expr`")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s compare [-catalog file] [-tz zone] [-f format] [-strict] [-filter expr] a-file b-file\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s compare [-catalog file] [-tz zone] [-f format] [-strict] [-filter expr] -a expr -b expr [file ...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	useCatalog(*catalogFile)
+	useTimeZone(*tz)
+
+	if *format != "text" && *format != "json" {
+		log.Fatalf("[INFO] unknown format %q (supported: json, text)\n", *format)
+	}
+	segments := *exprA != "" || *exprB != ""
+	if segments && (*exprA == "" || *exprB == "") {
+		log.Fatalf("[INFO] -a and -b must be given together\n")
+	}
+	if !segments && fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	read := func(filenames []string) []*Survey {
+		surveys, skipped, err := readSurveys(filenames, *strict)
+		if err != nil {
+			log.Fatalf("[INFO] Error reading surveys: %s\n", err)
+		}
+		printSkipped(skipped)
+		return filterSurveys(surveys, allFilters(filters))
+	}
+
+	var cmp Comparison
+	if segments {
+		surveys := read(fs.Args())
+		var sides [2][]*Survey
+		for i, expr := range []string{*exprA, *exprB} {
+			f, err := ParseFilter(expr)
+			if err != nil {
+				log.Fatalf("[INFO] %s\n", err)
+			}
+			sides[i] = filterSurveys(surveys, f)
+		}
+		cmp = NewComparison(*exprA, sides[0], *exprB, sides[1])
+	} else {
+		cmp = NewComparison(fs.Arg(0), read(fs.Args()[:1]), fs.Arg(1), read(fs.Args()[1:]))
+	}
+
+	if *format == "json" {
+		b, err := json.MarshalIndent(cmp, "", "  ")
+		if err != nil {
+			log.Fatalf("[INFO] Error rendering comparison: %s\n", err)
+		}
+		fmt.Printf("%s\n", b)
+		return
+	}
+	writeComparison(os.Stdout, cmp)
+}
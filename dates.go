@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// tzEnv is the environment variable naming the time zone of survey dates
+// that do not give one.
+const tzEnv = "DRIVING_TZ"
+
+// dateLayouts are the layouts accepted for survey dates: those the survey
+// platform emits in its cookie-jar, CSV and XLSX exports. The first two are
+// used to format dates read from XLSX cells.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02",
+	"01/02/2006",
+	"01/02/2006 15:04",
+	"01/02/2006 15:04:05",
+	"1/2/2006",
+	"1/2/2006 3:04:05 PM",
+	"1/2/2006 3:04 PM",
+	"02-Jan-2006",
+	"Jan 2, 2006",
+}
+
+// dateLocation is the time zone of survey dates that do not give one.
+var dateLocation = time.UTC
+
+// parseDate parses a survey date in any of dateLayouts. Dates without a time
+// zone are taken to be in dateLocation.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, dateLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// A Date is a survey date, such as a class's start date. It keeps the text
+// it was read from, so that it is written back, and identifies classes, as
+// it was given.
+type Date struct {
+	Time time.Time // Zero if the date was blank or invalid.
+	Raw  string
+}
+
+// Valid reports whether d holds a date.
+func (d Date) Valid() bool {
+	return !d.Time.IsZero()
+}
+
+func (d Date) String() string {
+	return d.Raw
+}
+
+// Day returns d's calendar day in dateLocation, such as "2016-09-01", so that
+// the same day written in different formats compares equal. It returns the
+// text d was read from if d holds no date.
+func (d Date) Day() string {
+	if !d.Valid() {
+		return d.Raw
+	}
+	return d.Time.In(dateLocation).Format(dateLayouts[0])
+}
+
+// asWritten formats d with layout in the time zone it was written in, or
+// dateLocation if it gave none, so that the same date and time written in
+// different formats compare equal. Unlike Day, the result does not depend on
+// -tz. It returns the text d was read from if d holds no date.
+func (d Date) asWritten(layout string) string {
+	if !d.Valid() {
+		return d.Raw
+	}
+	return d.Time.Format(layout)
+}
+
+// UnmarshalText parses a date in any of dateLayouts. Blank text gives the
+// zero Date. Text in none of them is kept as Raw, with a zero Time, rather
+// than rejected: neither date is needed to score a survey, so a typo in one
+// should not cost the learner's answers. validate reports such dates.
+func (d *Date) UnmarshalText(text []byte) error {
+	raw := strings.TrimSpace(string(text))
+	t, err := parseDate(raw)
+	if err != nil {
+		t = time.Time{}
+	}
+	*d = Date{Time: t, Raw: raw}
+	return nil
+}
+
+// MarshalText returns the text d was read from.
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.Raw), nil
+}
+
+// tzFlag adds the -tz flag to fs. The time zone it names must be put in use
+// with useTimeZone once fs is parsed.
+func tzFlag(fs *flag.FlagSet) *string {
+	return fs.String("tz", os.Getenv(tzEnv), "time zone of survey dates that give none, such as America/Toronto (default UTC, or from $"+tzEnv+")")
+}
+
+// useTimeZone puts the named time zone in use for survey dates, or keeps UTC
+// if name is empty.
+func useTimeZone(name string) {
+	if name == "" {
+		return
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Fatalf("[INFO] Error loading time zone: %s\n", err)
+	}
+	dateLocation = loc
+}
+
+// responseLag returns the number of days from the start of s's class to its
+// submission. ok is false if either date is missing or the survey is dated
+// before the class started.
+func (s *Survey) responseLag() (days float64, ok bool) {
+	if !s.StartDate.Valid() || !s.SurveyDate.Valid() {
+		return 0, false
+	}
+	days = s.SurveyDate.Time.Sub(s.StartDate.Time).Hours() / 24
+	return days, days >= 0
+}
+
+// lagBuckets are the buckets of the response lag distribution, each holding
+// the lags of fewer than Max whole days and at least the previous bucket's.
+var lagBuckets = []struct {
+	Label string
+	Max   int
+}{
+	{"same day", 1},
+	{"1 day", 2},
+	{"2-3 days", 4},
+	{"4-7 days", 8},
+	{"8-14 days", 15},
+	{"15+ days", math.MaxInt32},
+}
+
+// lagBucket returns the label of the bucket a lag of days falls in.
+func lagBucket(days float64) string {
+	for _, b := range lagBuckets {
+		if int(days) < b.Max {
+			return b.Label
+		}
+	}
+	return lagBuckets[len(lagBuckets)-1].Label
+}
+
+// A LagBucket counts the surveys whose response lag falls in one bucket.
+type LagBucket struct {
+	Label string
+	Count int
+}
+
+// LagStats summarises the response lags of a set of surveys, in days: the
+// time from the start of the class to submission of the survey. Only surveys
+// with both dates, submitted after the class started, are counted.
+type LagStats struct {
+	Mean    float64 // 0 if Count is 0.
+	Count   int
+	Buckets []LagBucket
+	Spread
+}
+
+// newLagStats returns the LagStats of surveys.
+func newLagStats(surveys []*Survey) LagStats {
+	var x sample
+	counts := make(map[string]int)
+	for _, s := range surveys {
+		if days, ok := s.responseLag(); ok {
+			x.add(days)
+			counts[lagBucket(days)]++
+		}
+	}
+	st := LagStats{Mean: x.mean(), Count: len(x), Spread: newSpread(x)}
+	for _, b := range lagBuckets {
+		st.Buckets = append(st.Buckets, LagBucket{b.Label, counts[b.Label]})
+	}
+	return st
+}
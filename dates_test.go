@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestDateUnmarshalText(t *testing.T) {
+	tests := []struct {
+		text  string
+		raw   string
+		valid bool
+	}{
+		{"2016-09-01", "2016-09-01", true},
+		{" 09/01/2016 ", "09/01/2016", true},
+		{"", "", false},
+		{"2016-13-45", "2016-13-45", false},
+		{"next tuesday", "next tuesday", false},
+	}
+	for _, tt := range tests {
+		var d Date
+		if err := d.UnmarshalText([]byte(tt.text)); err != nil {
+			t.Errorf("UnmarshalText(%q): %s", tt.text, err)
+			continue
+		}
+		if d.Raw != tt.raw || d.Valid() != tt.valid {
+			t.Errorf("UnmarshalText(%q) = %q, valid %v; want %q, valid %v", tt.text, d.Raw, d.Valid(), tt.raw, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// maxLineSize is the longest line, in bytes, that a Decoder will accept.
+// Comment answers can be long, so this is well above bufio's default.
+const maxLineSize = 1024 * 1024
+
+// A ParseError describes a problem decoding one survey record.
+type ParseError struct {
+	File   string // Name of the input, if known.
+	Line   int    // Line number of the problem, starting at 1.
+	Record int    // Record number within the input, starting at 1.
+	Field  string // Key of the offending field, if any.
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	var buf bytes.Buffer
+	if e.File != "" {
+		fmt.Fprintf(&buf, "%s:", e.File)
+	}
+	fmt.Fprintf(&buf, "%d: record %d: ", e.Line, e.Record)
+	if e.Field != "" {
+		fmt.Fprintf(&buf, "field %s: ", e.Field)
+	}
+	buf.WriteString(e.Err.Error())
+	return buf.String()
+}
+
+// A Decoder reads Surveys from cookie-jar format input.
+//
+// The cookie-jar format consists of "key=value" lines, one per survey field,
+// with records separated by a line containing only "=". Fields may appear in
+// any order, and values may themselves contain "=". Keys are matched to
+// Survey fields case-insensitively, ignoring dashes, underscores and spaces
+// (Q2-07 → Q207), and keys that match no field are ignored. Empty lines are
+// skipped.
+type Decoder struct {
+	// Name is the name of the input, such as a file name, used in
+	// ParseErrors.
+	Name string
+
+	scanner *bufio.Scanner
+	line    int
+	record  int
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	return &Decoder{scanner: scanner}
+}
+
+// A Field is a single key=value line of a cookie-jar record.
+type Field struct {
+	Key   string
+	Value string
+	Line  int // Line number, starting at 1.
+}
+
+// A Record is a single cookie-jar record, before it is decoded into a Survey.
+type Record struct {
+	File   string // Name of the input, if known.
+	Number int    // Record number within the input, starting at 1.
+	Fields []Field
+}
+
+// ReadRecord reads the next record from its input. It returns io.EOF when
+// there are no more records.
+//
+// Lines that are not in key=value form are left out of the record, and the
+// first of them is returned as a *ParseError along with the rest of the
+// record. Any other error means the input could not be read.
+func (d *Decoder) ReadRecord() (*Record, error) {
+	var rec *Record
+	var firstErr error
+	for d.scanner.Scan() {
+		d.line++
+		line := strings.TrimSuffix(d.scanner.Text(), "\r")
+
+		if line == "" {
+			continue
+		}
+
+		// Record delimiter. Skip empty records.
+		if line == "=" {
+			if rec == nil {
+				continue
+			}
+			return rec, firstErr
+		}
+
+		if rec == nil {
+			d.record++
+			rec = &Record{File: d.Name, Number: d.record}
+		}
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			if firstErr == nil {
+				firstErr = rec.errorf(d.line, "", "invalid line %q: missing \"=\"", line)
+			}
+			continue
+		}
+		rec.Fields = append(rec.Fields, Field{Key: line[:i], Value: line[i+1:], Line: d.line})
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, io.EOF
+	}
+	return rec, firstErr
+}
+
+// Decode reads the next record from its input and stores it in s. It returns
+// io.EOF when there are no more records.
+//
+// Decode always consumes a whole record, so that decoding can continue with
+// the next record after an error. If the record is malformed, s holds the
+// fields that were decoded successfully and the first problem is returned as
+// a *ParseError. Any other error means the input could not be read.
+func (d *Decoder) Decode(s *Survey) error {
+	return decodeSurvey(d, s)
+}
+
+// decodeSurvey reads the next record from rr and stores it in s, as
+// Decoder.Decode does.
+func decodeSurvey(rr RecordReader, s *Survey) error {
+	rec, err := rr.ReadRecord()
+	if rec == nil {
+		return err
+	}
+	if uerr := rec.Unmarshal(s); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// Unmarshal stores the record's fields in s. Answers to questions with no
+// Survey field of their own are stored in s.Extra, and other fields whose key
+// matches no Survey field are ignored. So are answers under the ID of a
+// question that the record's survey version asks under another key, since
+// they answer something else; they too are stored in s.Extra. If a value is
+// invalid, the remaining fields are still stored and the first problem is
+// returned as a *ParseError.
+func (rec *Record) Unmarshal(s *Survey) error {
+	*s = Survey{}
+	v := reflect.ValueOf(s).Elem()
+	version := catalog.version(rec.value("survey_ver"))
+
+	var firstErr error
+	for _, f := range rec.Fields {
+		key := normalizeKey(f.Key)
+		idx, ok := surveyFields[key]
+		if isQuestionKey(key) && (!ok || version.renumbered(questionID(key))) {
+			id := questionID(key)
+			if _, rated := version.questionByKey(id); rated {
+				var a Answer
+				if err := a.UnmarshalText([]byte(f.Value)); err != nil && firstErr == nil {
+					firstErr = rec.errorf(f.Line, f.Key, "%s", err)
+				}
+			}
+			if s.Extra == nil {
+				s.Extra = make(map[string]string)
+			}
+			s.Extra[id] = f.Value
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(idx), f.Value); err != nil && firstErr == nil {
+			firstErr = rec.errorf(f.Line, f.Key, "%s", err)
+		}
+	}
+	return firstErr
+}
+
+// value returns the value of the record's last field with the given key, or
+// "" if it has none.
+func (rec *Record) value(key string) string {
+	value := ""
+	for _, f := range rec.Fields {
+		if normalizeKey(f.Key) == normalizeKey(key) {
+			value = f.Value
+		}
+	}
+	return value
+}
+
+// errorf returns a ParseError for the given line and field of the record.
+func (rec *Record) errorf(line int, field, format string, args ...interface{}) *ParseError {
+	return &ParseError{
+		File:   rec.File,
+		Line:   line,
+		Record: rec.Number,
+		Field:  field,
+		Err:    fmt.Errorf(format, args...),
+	}
+}
+
+// normalizeKey returns the form of a cookie-jar key used to look it up in
+// surveyFields: lower case, without dashes, underscores or spaces, so that
+// "Q2-07" matches Q207 and "Start Date" matches start_date.
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.TrimSpace(key)))
+}
+
+// surveyFields maps normalized cookie-jar keys to the index of the matching
+// Survey field. A field's key is its column name, as given by surveyColumns.
+var surveyFields = func() map[string]int {
+	m := make(map[string]int)
+	for _, c := range surveyColumns() {
+		m[normalizeKey(c.Name)] = c.Index
+	}
+	return m
+}()
+
+// setField sets f, a Survey field, from its cookie-jar value.
+func setField(f reflect.Value, value string) error {
+	if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	if f.Kind() == reflect.String {
+		f.SetString(value)
+		return nil
+	}
+	return fmt.Errorf("unsupported field type %s", f.Type())
+}
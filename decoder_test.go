@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoderReadRecord(t *testing.T) {
+	type result struct {
+		fields []Field
+		err    bool // Whether a *ParseError is returned with the record.
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  []result
+	}{
+		{
+			name:  "values containing =",
+			input: "course=RH124\nQ403=2+2=4, a==b\n=\n",
+			want: []result{{fields: []Field{
+				{"course", "RH124", 1},
+				{"Q403", "2+2=4, a==b", 2},
+			}}},
+		},
+		{
+			name:  "CRLF line endings",
+			input: "course=RH124\r\nQ2-07=5\r\n=\r\ncourse=RH134\r\n=\r\n",
+			want: []result{
+				{fields: []Field{{"course", "RH124", 1}, {"Q2-07", "5", 2}}},
+				{fields: []Field{{"course", "RH134", 4}}},
+			},
+		},
+		{
+			name:  "missing =",
+			input: "course=RH124\nnot a field\nQ207=4\n=\ncourse=RH134\n=\n",
+			want: []result{
+				{fields: []Field{{"course", "RH124", 1}, {"Q207", "4", 3}}, err: true},
+				{fields: []Field{{"course", "RH134", 5}}},
+			},
+		},
+		{
+			name:  "empty lines and records, and no final delimiter",
+			input: "\n=\n=\n\ncourse=RH124\n\nQ207=\n",
+			want:  []result{{fields: []Field{{"course", "RH124", 5}, {"Q207", "", 7}}}},
+		},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(strings.NewReader(tt.input))
+		for i, want := range tt.want {
+			rec, err := d.ReadRecord()
+			if _, isParseErr := err.(*ParseError); err != nil && !isParseErr {
+				t.Fatalf("%s: record %d: %s", tt.name, i+1, err)
+			}
+			if (err != nil) != want.err {
+				t.Errorf("%s: record %d: error %v, want error: %v", tt.name, i+1, err, want.err)
+			}
+			if rec.Number != i+1 {
+				t.Errorf("%s: record %d: numbered %d", tt.name, i+1, rec.Number)
+			}
+			if !reflect.DeepEqual(rec.Fields, want.fields) {
+				t.Errorf("%s: record %d: fields %v, want %v", tt.name, i+1, rec.Fields, want.fields)
+			}
+		}
+		if _, err := d.ReadRecord(); err != io.EOF {
+			t.Errorf("%s: after the last record: error %v, want io.EOF", tt.name, err)
+		}
+	}
+}
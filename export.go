@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the version of the JSON export schema. It is increased
+// whenever a field is renamed or removed or its meaning changes; adding a
+// field does not change it.
+const SchemaVersion = 1
+
+// An exportDocument is the top level of a JSON export.
+type exportDocument struct {
+	SchemaVersion int          `json:"schema_version"`
+	Report        exportReport `json:"report"`
+}
+
+// An exportReport is the JSON export form of a Report.
+type exportReport struct {
+	Title          string            `json:"title"`
+	Group          map[string]string `json:"group"`
+	Responses      int               `json:"responses"`
+	SurveyVersions map[string]int    `json:"survey_versions"`
+	MixedVersions  bool              `json:"mixed_versions"`
+	Categories     []exportCategory  `json:"categories"`
+	NPS            exportNPS         `json:"nps"`
+	Readiness      []exportYesNo     `json:"readiness"`
+	ResponseLag    exportLag         `json:"response_lag"`
+	Groups         []exportReport    `json:"groups"`
+}
+
+// An exportCategory is the JSON export form of a Report's results for one
+// category of questions.
+type exportCategory struct {
+	Name      string              `json:"name"`
+	Mean      *float64            `json:"mean"` // null if Count is 0.
+	Count     int                 `json:"count"`
+	Spread    *exportSpread       `json:"spread"` // null if Count is 0.
+	Questions []exportQuestion    `json:"questions"`
+	Comments  map[string][]string `json:"comments"`
+}
+
+// An exportQuestion is the JSON export form of a QuestionStats.
+type exportQuestion struct {
+	ID        string        `json:"id"`
+	Text      string        `json:"text"`
+	Mean      *float64      `json:"mean"`
+	Count     int           `json:"count"`
+	Histogram []int         `json:"histogram"`
+	Spread    *exportSpread `json:"spread"`
+}
+
+// An exportSpread is the JSON export form of a Spread.
+type exportSpread struct {
+	StdDev float64 `json:"std_dev"`
+	Median float64 `json:"median"`
+	CILow  float64 `json:"ci_low"`
+	CIHigh float64 `json:"ci_high"`
+}
+
+// An exportNPS is the JSON export form of a Report's NPS results.
+type exportNPS struct {
+	Score          *float64 `json:"score"` // null if nobody answered.
+	CILow          *float64 `json:"ci_low"`
+	CIHigh         *float64 `json:"ci_high"`
+	Promoters      int      `json:"promoters"`
+	Passives       int      `json:"passives"`
+	Detractors     int      `json:"detractors"`
+	NonRespondents int      `json:"non_respondents"`
+}
+
+// An exportYesNo is the JSON export form of a YesNoStats.
+type exportYesNo struct {
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+	Yes   int    `json:"yes"`
+	No    int    `json:"no"`
+	Blank int    `json:"blank"`
+}
+
+// An exportLag is the JSON export form of a LagStats.
+type exportLag struct {
+	Mean    *float64          `json:"mean"` // null if Count is 0.
+	Count   int               `json:"count"`
+	Spread  *exportSpread     `json:"spread"`
+	Buckets []exportLagBucket `json:"buckets"`
+}
+
+// An exportLagBucket is the JSON export form of a LagBucket.
+type exportLagBucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// A category is a Report's results for one category of questions.
+type category struct {
+	Name      string
+	Label     string // Name as shown in reports.
+	Avg       float64
+	Count     int
+	Spread    Spread
+	Questions []QuestionStats
+	Comments  map[string][]string
+}
+
+// categories returns r's results by category, in survey order.
+func (r Report) categories() []category {
+	return []category{
+		{"curriculum", "Curriculum", r.CurriculumAvg, r.CurriculumCount, r.CurriculumSpread, r.CurriculumQuestions, r.CurriculumComments},
+		{"instructor", "Instructor", r.InstructorAvg, r.InstructorCount, r.InstructorSpread, r.InstructorQuestions, r.InstructorComments},
+		{"environment", "Environment", r.EnvironmentAvg, r.EnvironmentCount, r.EnvironmentSpread, r.EnvironmentQuestions, r.EnvironmentComments},
+		{"facility", "Facility", r.FacilityAvg, r.FacilityCount, r.FacilitySpread, r.FacilityQuestions, r.FacilityComments},
+		{"overall", "Overall", r.OverallAvg, r.OverallCount, r.OverallSpread, r.OverallQuestions, r.OverallComments},
+	}
+}
+
+// shownCategories returns the categories of r that apply to any of its
+// surveys, which are the ones text, HTML and PNG reports show. A category
+// that applies to none, such as Facility in a report on virtual classes, has
+// no questions.
+func (r Report) shownCategories() []category {
+	var shown []category
+	for _, c := range r.categories() {
+		if len(c.Questions) > 0 || c.Count > 0 {
+			shown = append(shown, c)
+		}
+	}
+	return shown
+}
+
+// newExportReport returns the JSON export form of r. Slices and maps are
+// never nil, so that they are exported as empty rather than null.
+func newExportReport(r Report) exportReport {
+	er := exportReport{
+		Title:          r.Title(),
+		Group:          r.Group,
+		Responses:      r.Responses,
+		SurveyVersions: r.SurveyVersions,
+		MixedVersions:  r.MixedVersions(),
+		Categories:     []exportCategory{},
+		NPS: exportNPS{
+			Promoters:      r.Promoters,
+			Passives:       r.Passives,
+			Detractors:     r.Detractors,
+			NonRespondents: r.NPSNonRespondents,
+		},
+		Readiness: []exportYesNo{},
+		ResponseLag: exportLag{
+			Mean:    optional(r.ResponseLag.Mean, r.ResponseLag.Count > 0),
+			Count:   r.ResponseLag.Count,
+			Spread:  newExportSpread(r.ResponseLag.Spread, r.ResponseLag.Count),
+			Buckets: []exportLagBucket{},
+		},
+		Groups: []exportReport{},
+	}
+	if er.Group == nil {
+		er.Group = map[string]string{}
+	}
+	if er.SurveyVersions == nil {
+		er.SurveyVersions = map[string]int{}
+	}
+	for _, c := range r.categories() {
+		ec := exportCategory{
+			Name:      c.Name,
+			Mean:      optional(c.Avg, c.Count > 0),
+			Count:     c.Count,
+			Spread:    newExportSpread(c.Spread, c.Count),
+			Questions: []exportQuestion{},
+			Comments:  c.Comments,
+		}
+		if ec.Comments == nil {
+			ec.Comments = map[string][]string{}
+		}
+		for _, q := range c.Questions {
+			ec.Questions = append(ec.Questions, exportQuestion{
+				ID:        q.ID,
+				Text:      q.Text,
+				Mean:      optional(q.Mean, q.Count > 0),
+				Count:     q.Count,
+				Histogram: q.Histogram,
+				Spread:    newExportSpread(q.Spread, q.Count),
+			})
+		}
+		er.Categories = append(er.Categories, ec)
+	}
+	er.NPS.Score = optional(r.NPS, r.NPSAvailable)
+	er.NPS.CILow = optional(r.NPSCILow, r.NPSAvailable)
+	er.NPS.CIHigh = optional(r.NPSCIHigh, r.NPSAvailable)
+	for _, st := range r.Readiness {
+		er.Readiness = append(er.Readiness, exportYesNo{st.ID, st.Text, st.Yes, st.No, st.Blank})
+	}
+	for _, b := range r.ResponseLag.Buckets {
+		er.ResponseLag.Buckets = append(er.ResponseLag.Buckets, exportLagBucket{b.Label, b.Count})
+	}
+	for _, g := range r.Groups {
+		er.Groups = append(er.Groups, newExportReport(g))
+	}
+	return er
+}
+
+// newExportSpread returns the export form of sp, a spread over count
+// values, or nil if count is 0.
+func newExportSpread(sp Spread, count int) *exportSpread {
+	if count == 0 {
+		return nil
+	}
+	return &exportSpread{sp.StdDev, sp.Median, sp.CILow, sp.CIHigh}
+}
+
+// optional returns a pointer to v if ok is true, and nil otherwise.
+func optional(v float64, ok bool) *float64 {
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// RenderJSON writes r as indented JSON following version SchemaVersion of
+// the export schema.
+func RenderJSON(w io.Writer, r Report) error {
+	b, err := json.MarshalIndent(exportDocument{SchemaVersion, newExportReport(r)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+// maxScale is the largest question scale; CSV reports have a count column
+// for each answer up to it.
+const maxScale = 10
+
+// RenderCSV writes r as CSV, with a row for each category, each question, the
+// NPS, each Yes/No question, the response lag and each lag bucket, for r and
+// each of its groups in turn. The first columns hold the values of the fields
+// the groups are split by, or "(all)" in r's own rows.
+func RenderCSV(w io.Writer, r Report) error {
+	var fields []string
+	if len(r.Groups) > 0 {
+		fields = sortedKeys(r.Groups[0].Group)
+	}
+
+	header := append([]string{}, fields...)
+	header = append(header, "category", "question", "mean", "count", "std_dev", "median", "ci_low", "ci_high")
+	for v := 1; v <= maxScale; v++ {
+		header = append(header, fmt.Sprintf("answered_%d", v))
+	}
+	header = append(header, "promoters", "passives", "detractors", "non_respondents", "yes", "no", "blank")
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, r := range append([]Report{r}, r.Groups...) {
+		prefix := make([]string, len(fields))
+		for i, f := range fields {
+			prefix[i] = "(all)"
+			if r.Group != nil {
+				prefix[i] = r.Group[f]
+			}
+		}
+		row := func(category, question string, mean float64, count int, sp Spread, histogram []int, nps, yesNo []string) {
+			rec := append(append([]string{}, prefix...), category, question)
+			rec = append(rec, formatCSVFloat(mean, count > 0), strconv.Itoa(count))
+			rec = append(rec, formatCSVFloat(sp.StdDev, count > 0), formatCSVFloat(sp.Median, count > 0))
+			rec = append(rec, formatCSVFloat(sp.CILow, count > 0), formatCSVFloat(sp.CIHigh, count > 0))
+			for v := 0; v < maxScale; v++ {
+				n := ""
+				if v < len(histogram) {
+					n = strconv.Itoa(histogram[v])
+				}
+				rec = append(rec, n)
+			}
+			if nps == nil {
+				nps = make([]string, 4)
+			}
+			if yesNo == nil {
+				yesNo = make([]string, 3)
+			}
+			rec = append(rec, nps...)
+			cw.Write(append(rec, yesNo...))
+		}
+		for _, c := range r.categories() {
+			row(c.Name, "", c.Avg, c.Count, c.Spread, nil, nil, nil)
+			for _, q := range c.Questions {
+				row(c.Name, q.ID, q.Mean, q.Count, q.Spread, q.Histogram, nil, nil)
+			}
+		}
+		row("nps", "", r.NPS, r.Promoters+r.Passives+r.Detractors,
+			Spread{CILow: r.NPSCILow, CIHigh: r.NPSCIHigh}, nil,
+			[]string{strconv.Itoa(r.Promoters), strconv.Itoa(r.Passives), strconv.Itoa(r.Detractors), strconv.Itoa(r.NPSNonRespondents)}, nil)
+		for _, st := range r.Readiness {
+			rec := append(append([]string{}, prefix...), "readiness", st.ID, "", strconv.Itoa(st.Total()))
+			rec = append(rec, make([]string, 4+maxScale+4)...)
+			cw.Write(append(rec, strconv.Itoa(st.Yes), strconv.Itoa(st.No), strconv.Itoa(st.Blank)))
+		}
+		row("response_lag", "", r.ResponseLag.Mean, r.ResponseLag.Count, r.ResponseLag.Spread, nil, nil, nil)
+		for _, b := range r.ResponseLag.Buckets {
+			rec := append(append([]string{}, prefix...), "response_lag", b.Label, "", strconv.Itoa(b.Count))
+			cw.Write(append(rec, make([]string, 4+maxScale+4+3)...))
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatCSVFloat formats v for a CSV report, or returns "" if ok is false.
+func formatCSVFloat(v float64, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// A surveyColumn is a Survey field that holds an answer, as named in CSV
+// headers and cookie-jar keys.
+type surveyColumn struct {
+	Name  string // The field's JSON name if it has one, otherwise its Go name.
+	Index int    // Index of the field in Survey.
+}
+
+// surveyColumns returns the columns of a Survey when written as CSV, in
+// field order. Extra is not a column; each of its answers is written in a
+// column of its own.
+func surveyColumns() []surveyColumn {
+	var cols []surveyColumn
+	t := reflect.TypeOf(Survey{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type != reflect.TypeOf("") && f.Type != reflect.TypeOf(Answer{}) && f.Type != reflect.TypeOf(Date{}) {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		cols = append(cols, surveyColumn{name, i})
+	}
+	return cols
+}
+
+// WriteSurveysCSV writes surveys as CSV, with a header row naming each
+// Survey field followed by one row per survey. Answers to questions without
+// a field of their own follow, in a column per question. Unanswered and N/A
+// rated questions are left empty.
+func WriteSurveysCSV(w io.Writer, surveys []*Survey) error {
+	cols := surveyColumns()
+	seen := make(map[string]bool)
+	var extraIDs []string
+	for _, s := range surveys {
+		for id := range s.Extra {
+			if !seen[id] {
+				seen[id] = true
+				extraIDs = append(extraIDs, id)
+			}
+		}
+	}
+	sort.Strings(extraIDs)
+
+	var header []string
+	for _, c := range cols {
+		header = append(header, c.Name)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(append(header, extraIDs...))
+	for _, s := range surveys {
+		v := reflect.ValueOf(s).Elem()
+		var rec []string
+		for _, c := range cols {
+			switch f := v.Field(c.Index).Interface().(type) {
+			case encoding.TextMarshaler:
+				b, _ := f.MarshalText()
+				rec = append(rec, string(b))
+			case string:
+				rec = append(rec, f)
+			}
+		}
+		for _, id := range extraIDs {
+			rec = append(rec, s.Extra[id])
+		}
+		cw.Write(rec)
+	}
+	cw.Flush()
+	return cw.Error()
+}
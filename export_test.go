@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// exportCatalog is a small catalog for export tests. Facility only applies
+// to onsite classes, so it is empty in reports on virtual ones.
+const exportCatalog = `{
+  "default": "2",
+  "versions": {
+    "2": {
+      "categories": [
+        {"name": "curriculum", "comment": "Q508", "questions": [
+          {"id": "Q207", "text": "Accurate student guide", "scale": 5},
+          {"id": "Q208", "text": "Logical structure", "scale": 5}
+        ]},
+        {"name": "facility", "modalities": ["ILT"], "questions": [
+          {"id": "Q609", "text": "Comfortable room", "scale": 5}
+        ]},
+        {"name": "overall", "questions": [
+          {"id": "Q311", "text": "Overall rating", "scale": 5},
+          {"id": "Q410", "text": "Would recommend", "scale": 10, "nps": true}
+        ]}
+      ],
+      "yes_no": [{"id": "Q109", "text": "Met prerequisites"}]
+    }
+  }
+}`
+
+// exportSurveys returns the surveys export tests report on: three learners
+// in two virtual classes, one of them taught by an instructor whose name
+// needs quoting in CSV.
+func exportSurveys(t *testing.T) []*Survey {
+	return decodeAll(t, `course=RH124
+instructor=Lee, "Ann"
+start_date=2016-09-12
+surveydate=2016-09-15
+modality=VT
+Q207=4
+Q208=5
+Q311=5
+Q410=10
+Q109=Yes
+Q508=Clear, "well paced"
+=
+course=RH124
+instructor=Lee, "Ann"
+start_date=2016-09-12
+surveydate=2016-09-12
+modality=VT
+Q207=2
+Q208=N/A
+Q311=3
+Q410=6
+Q109=No
+=
+course=RH124
+instructor=Bob Smith
+start_date=2016-09-19
+modality=VT
+Q207=5
+Q311=4
+=
+`)
+}
+
+// checkGolden compares got with the named file in testdata, or, with
+// -update, writes it there.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run go test -update to accept it):\n%s", golden, got)
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	useTestCatalog(t, exportCatalog)
+	var buf bytes.Buffer
+	if err := RenderCSV(&buf, NewGroupedReport(exportSurveys(t), false, []string{"instructor"})); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "report.csv", buf.Bytes())
+}
+
+func TestRenderJSON(t *testing.T) {
+	useTestCatalog(t, exportCatalog)
+	var buf bytes.Buffer
+	if err := RenderJSON(&buf, NewGroupedReport(exportSurveys(t), false, []string{"instructor"})); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "report.json", buf.Bytes())
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A Filter selects the surveys that feed a report.
+type Filter func(s *Survey) bool
+
+// filterOps are the comparison operators of filter expressions, longest
+// first so that "<=" is not read as "<".
+var filterOps = []string{"!=", "<=", ">=", "=", "<", ">", "~"}
+
+// ParseFilter parses a filter expression, as passed to -filter.
+//
+// An expression compares a survey field with a value, such as course=RH124,
+// start_date>=2016-09-01, instructor~"smith" or Q109=No. Fields are named as
+// cookie-jar keys are, ignoring case, dashes, underscores and spaces, and
+// values containing spaces or parentheses must be quoted. The operators are:
+//
+//	=, !=        equal or not, ignoring case and surrounding space
+//	<, <=, >, >= ordered as numbers if both sides are numbers, as dates if
+//	             both are dates, and as text otherwise
+//	~            matches a regular expression, ignoring case
+//
+// Comparisons can be combined with AND, OR and NOT, in decreasing order of
+// precedence from NOT, and grouped with parentheses.
+func ParseFilter(expr string) (Filter, error) {
+	toks, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{toks: toks}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("filter %q: unexpected %q", expr, p.toks[p.pos].text)
+	}
+	return f, nil
+}
+
+// allFilters returns a Filter matching the surveys that match every one of
+// fs. It matches every survey if fs is empty.
+func allFilters(fs []Filter) Filter {
+	return func(s *Survey) bool {
+		for _, f := range fs {
+			if !f(s) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// filterSurveys returns the surveys that f matches.
+func filterSurveys(surveys []*Survey, f Filter) []*Survey {
+	var matched []*Survey
+	for _, s := range surveys {
+		if f(s) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+// filterFlag is a flag.Value collecting filter expressions. It may be given
+// several times, and a survey must match them all.
+type filterFlag []Filter
+
+func (ff *filterFlag) String() string { return "" }
+
+func (ff *filterFlag) Set(expr string) error {
+	f, err := ParseFilter(expr)
+	if err != nil {
+		return err
+	}
+	*ff = append(*ff, f)
+	return nil
+}
+
+// A filterToken is a word, quoted string, operator or parenthesis of a filter
+// expression.
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeFilter splits a filter expression into tokens.
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var toks []filterToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')':
+			toks = append(toks, filterToken{text: string(c)})
+			i++
+		case c == '"':
+			s, err := strconv.QuotedPrefix(expr[i:])
+			if err != nil {
+				return nil, fmt.Errorf("filter %q: unterminated string", expr)
+			}
+			text, _ := strconv.Unquote(s)
+			toks = append(toks, filterToken{text: text, quoted: true})
+			i += len(s)
+		case strings.ContainsRune("!<>=~", rune(c)):
+			op := ""
+			for _, o := range filterOps {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("filter %q: invalid operator at %q", expr, expr[i:])
+			}
+			toks = append(toks, filterToken{text: op})
+			i += len(op)
+		default:
+			j := i
+			for j < len(expr) && !unicode.IsSpace(rune(expr[j])) && !strings.ContainsRune("()\"!<>=~", rune(expr[j])) {
+				j++
+			}
+			toks = append(toks, filterToken{text: expr[i:j]})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+// A filterParser parses filter tokens by recursive descent.
+type filterParser struct {
+	toks []filterToken
+	pos  int
+}
+
+// keyword reports whether the next token is the unquoted keyword kw, and
+// consumes it if so.
+func (p *filterParser) keyword(kw string) bool {
+	if p.pos < len(p.toks) && !p.toks[p.pos].quoted && strings.EqualFold(p.toks[p.pos].text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// next returns and consumes the next token, or returns an error if there
+// are none left.
+func (p *filterParser) next(what string) (filterToken, error) {
+	if p.pos >= len(p.toks) {
+		return filterToken{}, fmt.Errorf("filter ends where %s was expected", what)
+	}
+	p.pos++
+	return p.toks[p.pos-1], nil
+}
+
+// or parses comparisons combined with OR.
+func (p *filterParser) or() (Filter, error) {
+	f, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		g, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		f = func(f, g Filter) Filter { return func(s *Survey) bool { return f(s) || g(s) } }(f, g)
+	}
+	return f, nil
+}
+
+// and parses comparisons combined with AND.
+func (p *filterParser) and() (Filter, error) {
+	f, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		g, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		f = func(f, g Filter) Filter { return func(s *Survey) bool { return f(s) && g(s) } }(f, g)
+	}
+	return f, nil
+}
+
+// not parses a comparison or parenthesised expression, optionally negated.
+func (p *filterParser) not() (Filter, error) {
+	if p.keyword("not") {
+		f, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(s *Survey) bool { return !f(s) }, nil
+	}
+	if p.keyword("(") {
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(")") {
+			return nil, fmt.Errorf("filter: missing \")\"")
+		}
+		return f, nil
+	}
+	return p.comparison()
+}
+
+// comparison parses a field, operator and value.
+func (p *filterParser) comparison() (Filter, error) {
+	field, err := p.next("a field")
+	if err != nil {
+		return nil, err
+	}
+	value, err := filterField(field.text)
+	if err != nil {
+		return nil, err
+	}
+	op, err := p.next("an operator")
+	if err != nil {
+		return nil, err
+	}
+	operand, err := p.next("a value")
+	if err != nil {
+		return nil, err
+	}
+	want := strings.TrimSpace(operand.text)
+
+	switch op.text {
+	case "=":
+		return func(s *Survey) bool { return strings.EqualFold(strings.TrimSpace(value(s)), want) }, nil
+	case "!=":
+		return func(s *Survey) bool { return !strings.EqualFold(strings.TrimSpace(value(s)), want) }, nil
+	case "~":
+		re, err := regexp.Compile("(?i)" + operand.text)
+		if err != nil {
+			return nil, fmt.Errorf("filter: %s", err)
+		}
+		return func(s *Survey) bool { return re.MatchString(value(s)) }, nil
+	case "<", "<=", ">", ">=":
+		return func(s *Survey) bool {
+			v := strings.TrimSpace(value(s))
+			if v == "" {
+				return false
+			}
+			c := compareFilterValues(v, want)
+			switch op.text {
+			case "<":
+				return c < 0
+			case "<=":
+				return c <= 0
+			case ">":
+				return c > 0
+			}
+			return c >= 0
+		}, nil
+	}
+	return nil, fmt.Errorf("filter: expected an operator after %s, found %q", field.text, op.text)
+}
+
+// filterField returns a function giving the value of the named survey field,
+// or an error if no field or question has that name. Questions are looked up
+// in each survey's version of the catalog, and rated ones compare by the
+// answer reports count, so that a filter on Q207 means the same in every
+// version.
+func filterField(name string) (func(*Survey) string, error) {
+	key := normalizeKey(name)
+	if isQuestionKey(key) {
+		id := questionID(key)
+		return func(s *Survey) string { return s.questionValue(id) }, nil
+	}
+	idx, ok := surveyFields[key]
+	if !ok {
+		return nil, fmt.Errorf("filter: unknown field %q", name)
+	}
+	return func(s *Survey) string {
+		switch v := reflect.ValueOf(s).Elem().Field(idx).Interface().(type) {
+		case encoding.TextMarshaler:
+			b, _ := v.MarshalText()
+			return string(b)
+		case string:
+			return v
+		}
+		return ""
+	}, nil
+}
+
+// compareFilterValues returns -1, 0 or 1 as a is less than, equal to or
+// greater than b, comparing them as numbers or dates if both are, and as
+// text otherwise.
+func compareFilterValues(a, b string) int {
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			return compareFloats(x, y)
+		}
+	}
+	if x, err := parseDate(a); err == nil {
+		if y, err := parseDate(b); err == nil {
+			switch {
+			case x.Before(y):
+				return -1
+			case x.After(y):
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// compareFloats returns -1, 0 or 1 as x is less than, equal to or greater
+// than y.
+func compareFloats(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
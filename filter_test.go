@@ -0,0 +1,208 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	date := func(s string) Date {
+		var d Date
+		d.UnmarshalText([]byte(s))
+		return d
+	}
+	surveys := []*Survey{
+		{Course: "RH124", Instructor: "Bob Smith", StartDate: date("2016-09-01"), Q207: Answer{4, true}, Q109: "Yes", Modality: "ILT"},
+		{Course: "RH134", Instructor: "Ann Lee", StartDate: date("08/15/2016"), Q207: Answer{2, true}, Q109: "No", Modality: "VT"},
+		{Course: "RH124", Instructor: "Ann Lee", StartDate: date("2016-10-03"), Q207: Answer{10, true}},
+		{Course: "RH124", Instructor: "Bob Smith"},
+	}
+	tests := []struct {
+		expr string
+		want []int // Indexes of the matching surveys.
+	}{
+		{"course=RH124", []int{0, 2, 3}},
+		{"course = rh124", []int{0, 2, 3}},
+		{`Course_Ver=""`, []int{0, 1, 2, 3}},
+		{"course!=RH124", []int{1}},
+		{`instructor="Ann Lee"`, []int{1, 2}},
+		{`instructor~"^bob"`, []int{0, 3}},
+		{"q109=no", []int{1}},
+		{"Q2-07>3", []int{0, 2}},
+		{"q207<=4", []int{0, 1}},
+
+		// Numbers compare as numbers, not text, and dates as dates in any
+		// supported format. Blank values never match an ordering.
+		{"q207>=10", []int{2}},
+		{"start_date>=2016-09-01", []int{0, 2}},
+		{"start_date<09/01/2016", []int{1}},
+
+		// NOT binds tighter than AND, which binds tighter than OR.
+		{"course=RH134 OR course=RH124 AND instructor~ann", []int{1, 2}},
+		{"(course=RH134 OR course=RH124) AND instructor~ann", []int{1, 2}},
+		{"(course=RH134 OR instructor~bob) AND q207>3", []int{0}},
+		{"NOT course=RH124 OR modality=ILT", []int{0, 1}},
+		{"not (course=RH124 or modality=VT)", nil},
+		{"course=RH124 and not q109=yes", []int{2, 3}},
+
+		// Keywords are case-insensitive, but not when quoted.
+		{`instructor="and"`, nil},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %s", tt.expr, err)
+			continue
+		}
+		var got []int
+		for i, s := range surveys {
+			if f(s) {
+				got = append(got, i)
+			}
+		}
+		if !equalInts(got, tt.want) {
+			t.Errorf("ParseFilter(%q) matches %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// testCatalog is a catalog with two versions: version 1 asks Q207 under the
+// key Q201 on a scale of 10, and Q109 under Q105; version 2 asks Q201 and
+// Q207 under their IDs on a scale of 5.
+const testCatalog = `{
+  "default": "2",
+  "versions": {
+    "1": {
+      "categories": [
+        {"name": "curriculum", "questions": [
+          {"id": "Q207", "key": "Q201", "text": "Accurate student guide", "scale": 10}
+        ]}
+      ],
+      "yes_no": [{"id": "Q109", "key": "Q105", "text": "Met prerequisites"}]
+    },
+    "2": {
+      "categories": [
+        {"name": "curriculum", "questions": [
+          {"id": "Q201", "text": "Clear objectives", "scale": 5},
+          {"id": "Q207", "text": "Accurate student guide", "scale": 5}
+        ]}
+      ],
+      "yes_no": [{"id": "Q109", "text": "Met prerequisites"}]
+    }
+  }
+}`
+
+// useTestCatalog puts the catalog in JSON form c in use until the test
+// ends.
+func useTestCatalog(t *testing.T, c string) {
+	saved := catalog
+	catalog = mustParseCatalog(c)
+	t.Cleanup(func() { catalog = saved })
+}
+
+// decodeAll decodes the surveys in the cookie-jar text input.
+func decodeAll(t *testing.T, input string) []*Survey {
+	var surveys []*Survey
+	d := NewDecoder(strings.NewReader(input))
+	for {
+		s := new(Survey)
+		err := d.Decode(s)
+		if err == io.EOF {
+			return surveys
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		surveys = append(surveys, s)
+	}
+}
+
+func TestParseFilterVersions(t *testing.T) {
+	useTestCatalog(t, testCatalog)
+	surveys := decodeAll(t, `survey_ver=1
+Q201=10
+Q207=1
+Q105=Yes
+=
+survey_ver=1
+Q201=4
+Q109=Yes
+=
+survey_ver=2
+Q201=1
+Q207=5
+Q109=No
+=
+survey_ver=2
+Q201=5
+Q207=N/A
+=
+`)
+	tests := []struct {
+		expr string
+		want []int
+	}{
+		// Version 1 answers Q207 under Q201, on a scale of 10: 10 counts
+		// as 5 and 4 as 2⅓. Its Q207 answers something else.
+		{"Q207>=4", []int{0, 2}},
+		{"Q207<3", []int{1}},
+		{"Q207=5", []int{0, 2}},
+		{`Q207=""`, []int{3}},
+
+		// Q201 is not asked in version 1: its answers are Q207's.
+		{"Q201>=4", []int{3}},
+		{`Q201!=""`, []int{2, 3}},
+
+		{"Q109=yes", []int{0}},
+		{"Q109=no", []int{2}},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %s", tt.expr, err)
+			continue
+		}
+		var got []int
+		for i, s := range surveys {
+			if f(s) {
+				got = append(got, i)
+			}
+		}
+		if !equalInts(got, tt.want) {
+			t.Errorf("ParseFilter(%q) matches %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"course",
+		"course=",
+		"nosuchfield=1",
+		"course RH124",
+		"course=RH124 AND",
+		"(course=RH124",
+		"course=RH124)",
+		`instructor="unterminated`,
+		"instructor~(",
+		"course=!RH124",
+	} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// groupFields maps the Survey fields reports can be grouped by (as named in
+// the cookie-jar format) to a function returning that field's value.
+var groupFields = map[string]func(*Survey) string{
+	"country":    func(s *Survey) string { return s.Country },
+	"course":     func(s *Survey) string { return s.Course },
+	"course_ver": func(s *Survey) string { return s.CourseVer },
+	"instructor": func(s *Survey) string { return s.Instructor },
+	"language":   func(s *Survey) string { return s.Language },
+	"modality":   func(s *Survey) string { return s.Modality },
+	"response_lag": func(s *Survey) string {
+		if days, ok := s.responseLag(); ok {
+			return lagBucket(days)
+		}
+		return ""
+	},
+}
+
+// GroupFields returns the sorted names of all fields reports can be grouped
+// by. Reports can also be grouped by the answer to any Yes/No question in the
+// catalog, named by its ID.
+func GroupFields() []string {
+	return sortedKeys(groupFields)
+}
+
+// ParseGroupBy parses a comma-separated list of group fields, as passed to
+// -group-by. Yes/No questions are returned by ID, such as "Q109".
+func ParseGroupBy(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if _, ok := groupFields[f]; !ok {
+			if id := questionID(f); isQuestionKey(normalizeKey(f)) && catalog.isYesNo(id) {
+				f = id
+			} else {
+				return nil, fmt.Errorf("unknown group field %q (supported: %s, or a Yes/No question such as Q109)", f, strings.Join(GroupFields(), ", "))
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// groupValue returns the value of the group field f for s. The value of a
+// Yes/No question is the normalised answer to it, "" if s left it blank.
+func groupValue(s *Survey, f string) string {
+	if fn, ok := groupFields[f]; ok {
+		return fn(s)
+	}
+	q, ok := catalog.version(s.SurveyVer).yesNoQuestion(f)
+	if !ok {
+		return ""
+	}
+	return yesNo(s.value(q.key()))
+}
+
+// NewGroupedReport returns a roll-up Report over all surveys, with a Report
+// for each distinct combination of values of the by fields in its Groups. If
+// by is empty, it is the same as NewReport.
+func NewGroupedReport(surveys []*Survey, names bool, by []string) Report {
+	r := NewReport(surveys, names)
+	if len(by) == 0 {
+		return r
+	}
+
+	groups := make(map[string][]*Survey)
+	var keys []string
+	for _, s := range surveys {
+		key := groupKey(s, by)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], s)
+	}
+
+	for _, key := range keys {
+		members := groups[key]
+		g := NewReport(members, names)
+		g.Group = make(map[string]string)
+		for _, f := range by {
+			g.Group[f] = groupValue(members[0], f)
+		}
+		r.Groups = append(r.Groups, g)
+	}
+	sort.Slice(r.Groups, func(i, j int) bool {
+		return r.Groups[i].Title() < r.Groups[j].Title()
+	})
+	return r
+}
+
+// groupKey returns a string identifying the group s belongs to when grouping
+// by the given fields.
+func groupKey(s *Survey, by []string) string {
+	values := make([]string, len(by))
+	for i, f := range by {
+		values[i] = groupValue(s, f)
+	}
+	return strings.Join(values, "\x00")
+}
+
+// Title returns a short description of the surveys r covers, such as
+// "course=RH124, instructor=John Smith", or "All surveys" if r is not a
+// group.
+func (r Report) Title() string {
+	if len(r.Group) == 0 {
+		return "All surveys"
+	}
+	var parts []string
+	for _, f := range sortedKeys(r.Group) {
+		v := r.Group[f]
+		if v == "" {
+			v = "(unknown)"
+		}
+		parts = append(parts, f+"="+v)
+	}
+	return strings.Join(parts, ", ")
+}
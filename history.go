@@ -0,0 +1,382 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// historyEnv is the environment variable that overrides the default history
+// directory.
+const historyEnv = "DRIVING_HISTORY"
+
+// DefaultHistoryDir returns the history directory used when none is given on
+// the command line: $DRIVING_HISTORY if set, otherwise ~/.driving/history.
+func DefaultHistoryDir() string {
+	if dir := os.Getenv(historyEnv); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".driving", "history")
+}
+
+// A Class identifies a single delivery of a course.
+type Class struct {
+	Course     string
+	Instructor string
+	StartDate  string // Such as "2016-09-01", as returned by Date.asWritten.
+}
+
+// classOf returns the Class s was a response to. Its start date is the day
+// as written rather than in the -tz time zone, so that saving with another
+// -tz does not split a class across two files.
+func classOf(s *Survey) Class {
+	return Class{Course: s.Course, Instructor: s.Instructor, StartDate: s.StartDate.asWritten(dateLayouts[0])}
+}
+
+// filename returns the name of the file the class is saved in. It is readable
+// but, since distinct classes may have the same readable form, ends with a
+// hash of the exact class.
+func (c Class) filename() string {
+	h := sha1.Sum([]byte(c.Course + "\x00" + c.Instructor + "\x00" + c.StartDate))
+	return fmt.Sprintf("%s_%s_%s_%x.json", slug(c.StartDate), slug(c.Course), slug(c.Instructor), h[:4])
+}
+
+// slug returns s with every run of characters other than letters and digits
+// replaced by a single dash.
+func slug(s string) string {
+	f := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(f) == 0 {
+		return "unknown"
+	}
+	return strings.Join(f, "-")
+}
+
+// A ClassHistory is everything saved about one class: its responses. Reports
+// on it are computed when needed, so that they follow the catalog in use.
+type ClassHistory struct {
+	Class
+	Saved   time.Time
+	Surveys []*Survey
+}
+
+// A Store saves class histories as JSON files in a directory, one file per
+// class.
+type Store struct {
+	Dir string
+}
+
+// Save merges surveys into the store, class by class. Responses already in the store, as
+// identified by responseKeys, are not added again, so saving the same surveys
+// twice has no further effect. It returns the number of classes touched and
+// of responses added.
+func (st Store) Save(surveys []*Survey) (classes, added int, err error) {
+	if err := os.MkdirAll(st.Dir, 0755); err != nil {
+		return 0, 0, err
+	}
+
+	byClass := make(map[Class][]*Survey)
+	for _, s := range surveys {
+		c := classOf(s)
+		byClass[c] = append(byClass[c], s)
+	}
+
+	for c, ss := range byClass {
+		h, err := st.load(c.filename())
+		if os.IsNotExist(err) {
+			h, err = &ClassHistory{Class: c}, nil
+		}
+		if err != nil {
+			return classes, added, err
+		}
+
+		seen := make(map[string]bool)
+		for _, k := range responseKeys(h.Surveys) {
+			seen[k] = true
+		}
+		n := 0
+		for i, k := range responseKeys(ss) {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			h.Surveys = append(h.Surveys, ss[i])
+			n++
+		}
+		classes++
+		if n == 0 {
+			continue
+		}
+
+		h.Saved = time.Now().UTC()
+		if err := st.write(c.filename(), h); err != nil {
+			return classes, added, err
+		}
+		added += n
+	}
+	return classes, added, nil
+}
+
+// Load returns every class history in the store, ordered by start date,
+// course and instructor.
+func (st Store) Load() ([]*ClassHistory, error) {
+	names, err := filepath.Glob(filepath.Join(st.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var hs []*ClassHistory
+	for _, name := range names {
+		h, err := st.load(filepath.Base(name))
+		if err != nil {
+			return nil, err
+		}
+		hs = append(hs, h)
+	}
+	sort.Slice(hs, func(i, j int) bool {
+		a, b := hs[i].Class, hs[j].Class
+		if a.StartDate != b.StartDate {
+			return a.StartDate < b.StartDate
+		}
+		if a.Course != b.Course {
+			return a.Course < b.Course
+		}
+		return a.Instructor < b.Instructor
+	})
+	return hs, nil
+}
+
+// load reads the class history saved in the named file.
+func (st Store) load(name string) (*ClassHistory, error) {
+	b, err := ioutil.ReadFile(filepath.Join(st.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	var h ClassHistory
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return &h, nil
+}
+
+// write saves h in the named file. The file is replaced atomically, so an
+// interrupted save never leaves a partly written history behind.
+func (st Store) write(name string, h *ClassHistory) error {
+	b, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(st.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(st.Dir, name))
+}
+
+// responseKeys returns a key identifying each of surveys, responses to one
+// class, among that class's responses. A response is identified by the
+// learner's email, or else name, and the time the survey was submitted, in
+// whichever format it was exported.
+// Others, such as anonymous ones, are identified by their contents and the
+// number of identical responses before them in surveys: learners who gave
+// the same answers are all kept, while saving the same file again adds
+// nothing.
+func responseKeys(surveys []*Survey) []string {
+	keys := make([]string, len(surveys))
+	copies := make(map[string]int)
+	for i, s := range surveys {
+		learner := strings.ToLower(strings.TrimSpace(s.Email))
+		if learner == "" {
+			learner = strings.ToLower(strings.TrimSpace(s.Name))
+		}
+		if learner != "" && s.SurveyDate.Raw != "" {
+			keys[i] = "learner\x00" + learner + "\x00" + s.SurveyDate.asWritten(dateLayouts[1])
+			continue
+		}
+		b, _ := json.Marshal(s)
+		h := sha1.Sum(b)
+		copies[string(h[:])]++
+		keys[i] = fmt.Sprintf("content\x00%x\x00%d", h, copies[string(h[:])])
+	}
+	return keys
+}
+
+// periods maps the names of the periods history can be bucketed into to a
+// function returning the period a date falls in.
+var periods = map[string]func(time.Time) string{
+	"week": func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	},
+	"month": func(t time.Time) string {
+		return t.Format("2006-01")
+	},
+	"quarter": func(t time.Time) string {
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+	},
+	"year": func(t time.Time) string {
+		return t.Format("2006")
+	},
+}
+
+// periodOf returns the period, as named by the period function, that a class
+// starting on startDate falls in, or "unknown" if the date is invalid.
+func periodOf(period func(time.Time) string, startDate string) string {
+	t, err := parseDate(strings.TrimSpace(startDate))
+	if err != nil {
+		return "unknown"
+	}
+	return period(t)
+}
+
+// A PeriodReport is a Report over all classes starting in a period.
+type PeriodReport struct {
+	Period  string
+	Classes int
+	Report  Report
+}
+
+// historyFlags adds the flags shared by the commands that use the history
+// store to fs, and returns the store they select.
+func historyFlags(fs *flag.FlagSet) *Store {
+	st := &Store{}
+	fs.StringVar(&st.Dir, "dir", DefaultHistoryDir(), "history directory (default from $"+historyEnv+")")
+	return st
+}
+
+// runSave implements the save command, which adds the surveys in the given
+// files to the history store.
+func runSave(args []string) {
+	fs := flag.NewFlagSet("save", flag.ExitOnError)
+	st := historyFlags(fs)
+	strict := fs.Bool("strict", false, "fail on the first malformed record instead of skipping it")
+	catalogFile := catalogFlag(fs)
+	tz := tzFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s save [-catalog file] [-tz zone] [-dir dir] [-strict] [file ...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	useCatalog(*catalogFile)
+	useTimeZone(*tz)
+
+	surveys, skipped, err := readSurveys(fs.Args(), *strict)
+	if err != nil {
+		log.Fatalf("[INFO] Error reading surveys: %s\n", err)
+	}
+	printSkipped(skipped)
+
+	classes, added, err := st.Save(surveys)
+	if err != nil {
+		log.Fatalf("[INFO] Error saving history: %s\n", err)
+	}
+	fmt.Printf("Saved %d new response(s) across %d class(es) in %s\n", added, classes, st.Dir)
+}
+
+// runHistory implements the history command, which prints cumulative and
+// per-period results from the history store.
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	st := historyFlags(fs)
+	period := fs.String("period", "month", "period to bucket classes by (week, month, quarter, year)")
+	format := fs.String("f", "text", "output format (text, json)")
+	catalogFile := catalogFlag(fs)
+	tz := tzFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s history [-catalog file] [-tz zone] [-dir dir] [-period period] [-f format]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	useCatalog(*catalogFile)
+	useTimeZone(*tz)
+
+	periodFunc, ok := periods[*period]
+	if !ok {
+		log.Fatalf("[INFO] unknown period %q (supported: week, month, quarter, year)\n", *period)
+	}
+	if *format != "text" && *format != "json" {
+		log.Fatalf("[INFO] unknown format %q (supported: json, text)\n", *format)
+	}
+
+	hs, err := st.Load()
+	if err != nil {
+		log.Fatalf("[INFO] Error loading history: %s\n", err)
+	}
+
+	var all []*Survey
+	var prs []PeriodReport
+	byPeriod := make(map[string][]*ClassHistory)
+	var keys []string
+	for _, h := range hs {
+		all = append(all, h.Surveys...)
+		p := periodOf(periodFunc, h.StartDate)
+		if _, ok := byPeriod[p]; !ok {
+			keys = append(keys, p)
+		}
+		byPeriod[p] = append(byPeriod[p], h)
+	}
+	sort.Strings(keys)
+	for _, p := range keys {
+		var surveys []*Survey
+		for _, h := range byPeriod[p] {
+			surveys = append(surveys, h.Surveys...)
+		}
+		prs = append(prs, PeriodReport{Period: p, Classes: len(byPeriod[p]), Report: NewReport(surveys, false)})
+	}
+	total := PeriodReport{Period: "all", Classes: len(hs), Report: NewReport(all, false)}
+
+	if *format == "json" {
+		b, err := json.MarshalIndent(struct {
+			Periods    []PeriodReport
+			Cumulative PeriodReport
+		}{prs, total}, "", "  ")
+		if err != nil {
+			log.Fatalf("[INFO] Error rendering history: %s\n", err)
+		}
+		fmt.Printf("%s\n", b)
+		return
+	}
+	writeHistory(os.Stdout, prs, total)
+}
+
+// writeHistory writes a table with a row of category averages for each
+// period report, followed by the cumulative total.
+func writeHistory(w io.Writer, prs []PeriodReport, total PeriodReport) {
+	row := func(pr PeriodReport) {
+		r := pr.Report
+		writeLine(w, "%-9s %7d %9d %10s %10s %11s %8s %7s %6s",
+			pr.Period, pr.Classes, r.Responses,
+			formatAvg(r.CurriculumAvg, r.CurriculumCount),
+			formatAvg(r.InstructorAvg, r.InstructorCount),
+			formatAvg(r.EnvironmentAvg, r.EnvironmentCount),
+			formatAvg(r.FacilityAvg, r.FacilityCount),
+			formatAvg(r.OverallAvg, r.OverallCount),
+			r.formatNPS())
+	}
+	fmt.Fprintf(w, "%-9s %7s %9s %10s %10s %11s %8s %7s %6s\n",
+		"Period", "Classes", "Responses", "Curriculum", "Instructor", "Environment", "Facility", "Overall", "NPS")
+	for _, pr := range prs {
+		row(pr)
+	}
+	row(total)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseKeys(t *testing.T) {
+	date := func(s string) Date {
+		var d Date
+		d.UnmarshalText([]byte(s))
+		return d
+	}
+	surveys := []*Survey{
+		{Email: "ann@example.com", SurveyDate: date("2016-09-02"), Q207: Answer{4, true}},
+		{Email: " Ann@Example.com", SurveyDate: date("2016-09-02"), Q207: Answer{5, true}},
+		{Email: "ann@example.com", SurveyDate: date("2016-09-03"), Q207: Answer{4, true}},
+		{Name: "Bob Smith", SurveyDate: date("2016-09-02"), Q207: Answer{4, true}},
+		{Q207: Answer{4, true}},
+		{Q207: Answer{4, true}},
+		{Q207: Answer{3, true}},
+	}
+	keys := responseKeys(surveys)
+
+	// The same learner submitting at the same time is one response, even
+	// if the answers differ. Identical anonymous responses are kept apart.
+	if keys[0] != keys[1] {
+		t.Errorf("surveys 0 and 1 have different keys")
+	}
+	seen := make(map[string]int)
+	for i, k := range keys {
+		if i == 1 {
+			continue
+		}
+		if j, ok := seen[k]; ok {
+			t.Errorf("surveys %d and %d have the same key", j, i)
+		}
+		seen[k] = i
+	}
+
+	// Saving the same surveys again gives the same keys.
+	again := responseKeys(surveys)
+	for i := range keys {
+		if keys[i] != again[i] {
+			t.Errorf("survey %d: key changed from %q to %q", i, keys[i], again[i])
+		}
+	}
+}
+
+func TestStoreSaveAgain(t *testing.T) {
+	survey := func(start, submitted string, q207 int) *Survey {
+		s := &Survey{Course: "RH124", Instructor: "Ann Lee", Email: "bob@example.com", Q207: Answer{q207, true}}
+		s.StartDate.UnmarshalText([]byte(start))
+		s.SurveyDate.UnmarshalText([]byte(submitted))
+		return s
+	}
+	st := Store{Dir: t.TempDir()}
+	classes, added, err := st.Save([]*Survey{
+		survey("2016-09-01T22:00:00-05:00", "2016-09-02 14:03:00", 4),
+		survey("2016-09-01T22:00:00-05:00", "2016-09-03 09:30:00", 5),
+	})
+	if err != nil || classes != 1 || added != 2 {
+		t.Fatalf("first Save = %d, %d, %v; want 1, 2, nil", classes, added, err)
+	}
+
+	// The same responses, with survey dates exported in another format and
+	// saved with another -tz, are the same class and add nothing. The start
+	// date falls on different days in the two time zones.
+	saved := dateLocation
+	dateLocation = time.FixedZone("UTC-5", -5*60*60)
+	defer func() { dateLocation = saved }()
+	classes, added, err = st.Save([]*Survey{
+		survey("2016-09-01T22:00:00-05:00", "09/02/2016 14:03", 4),
+		survey("2016-09-01T22:00:00-05:00", "9/3/2016 9:30 AM", 5),
+	})
+	if err != nil || classes != 1 || added != 0 {
+		t.Errorf("second Save = %d, %d, %v; want 1, 0, nil", classes, added, err)
+	}
+	hs, err := st.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hs) != 1 || len(hs[0].Surveys) != 2 || hs[0].StartDate != "2016-09-01" {
+		t.Errorf("Load = %d class(es), want 1 of 2 responses starting on 2016-09-01", len(hs))
+	}
+}
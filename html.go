@@ -0,0 +1,405 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"image/color"
+	"io"
+	"math"
+)
+
+// Layout of SVG charts in HTML reports, in SVG user units.
+const (
+	svgWidth  = 780
+	svgLabelW = 110                       // width of the labels left of bars
+	svgPlotW  = 500                       // width of bars
+	svgValueX = svgLabelW + svgPlotW + 10 // left edge of values right of bars
+	svgRowH   = 22                        // height of a row of bars
+	svgBarH   = 16                        // height of a bar
+	svgAxisH  = 20                        // height of an axis with labels
+	svgLegend = 22                        // height of a legend
+)
+
+// RenderHTML writes r as a single self-contained HTML page, with inline CSS
+// and SVG charts, that needs no network access to display. Comments are
+// escaped by html/template and shown in collapsible sections.
+func RenderHTML(w io.Writer, r Report) error {
+	return htmlTmpl.Execute(w, r)
+}
+
+// An svgChart is the data passed to the "chart" HTML template: a horizontal
+// bar chart with one row per item, an optional axis and an optional legend.
+type svgChart struct {
+	Height int
+	Rows   []svgRow
+	Axis   []svgTick
+	AxisY  int
+	Legend []svgLegendItem
+	Note   string // Shown after the legend, at NoteX.
+	NoteX  int
+}
+
+// An svgRow is one row of an svgChart.
+type svgRow struct {
+	Y       int
+	Label   string
+	Title   string // Tooltip for the label.
+	Value   string // Shown right of the bars.
+	Empty   bool   // No answers; Bars is empty.
+	Bars    []svgBar
+	Whisker *svgWhisker
+}
+
+// An svgBar is a bar, or a segment of a stacked bar, in an svgRow.
+type svgBar struct {
+	X, W  float64
+	Fill  string
+	Title string
+}
+
+// An svgWhisker marks a confidence interval on a bar.
+type svgWhisker struct {
+	X0, X1 float64
+}
+
+// An svgTick is a labelled tick on an svgChart's axis.
+type svgTick struct {
+	X     float64
+	Label string
+}
+
+// An svgLegendItem is a colored square and its label.
+type svgLegendItem struct {
+	X     int
+	Fill  string
+	Label string
+}
+
+// categoryChart returns a chart of r's category averages on a scale from 0
+// to the catalog's score scale, with whiskers for their confidence
+// intervals.
+func categoryChart(r Report) svgChart {
+	var ch svgChart
+	max := float64(catalog.scoreScale())
+	for i, cat := range r.shownCategories() {
+		row := svgRow{
+			Y:     i * svgRowH,
+			Label: cat.Label,
+			Value: fmt.Sprintf("%s (n=%d)", formatAvg(cat.Avg, cat.Count), cat.Count),
+			Empty: cat.Count == 0,
+		}
+		if cat.Count > 0 {
+			row.Bars = []svgBar{{
+				W:     svgScale(cat.Avg, 0, max),
+				Fill:  cssColor(colorBar),
+				Title: fmt.Sprintf("%s: %s, %s", cat.Label, formatAvg(cat.Avg, cat.Count), formatSpread(cat.Spread, cat.Count)),
+			}}
+		}
+		if cat.Count > 1 {
+			row.Whisker = &svgWhisker{svgScale(cat.Spread.CILow, 0, max), svgScale(cat.Spread.CIHigh, 0, max)}
+		}
+		ch.Rows = append(ch.Rows, row)
+	}
+	ch.AxisY = len(ch.Rows) * svgRowH
+	for v := 0; v <= int(max); v++ {
+		ch.Axis = append(ch.Axis, svgTick{svgScale(float64(v), 0, max), fmt.Sprint(v)})
+	}
+	ch.Height = ch.AxisY + svgAxisH
+	return ch
+}
+
+// questionChart returns a chart of the distribution of answers to each of
+// qs, as stacked bars colored from red for 1 to green for the top answer.
+func questionChart(qs []QuestionStats) svgChart {
+	var ch svgChart
+	for i, q := range qs {
+		row := svgRow{
+			Y:     i * svgRowH,
+			Label: q.ID,
+			Title: q.Text,
+			Value: fmt.Sprintf("%s (n=%d)", formatAvg(q.Mean, q.Count), q.Count),
+			Empty: q.Count == 0,
+		}
+		sum := 0
+		for v, n := range q.Histogram {
+			if n == 0 {
+				continue
+			}
+			row.Bars = append(row.Bars, svgBar{
+				X:     svgPlotW * float64(sum) / float64(q.Count),
+				W:     svgPlotW * float64(n) / float64(q.Count),
+				Fill:  cssColor(scaleColor(v+1, len(q.Histogram))),
+				Title: fmt.Sprintf("%d: %d of %d (%.0f%%)", v+1, n, q.Count, 100*float64(n)/float64(q.Count)),
+			})
+			sum += n
+		}
+		ch.Rows = append(ch.Rows, row)
+	}
+	y := len(ch.Rows) * svgRowH
+	x := svgLabelW
+	scale, note := answerLegend(qs)
+	for v := 1; v <= scale; v++ {
+		ch.Legend = append(ch.Legend, svgLegendItem{x, cssColor(scaleColor(v, scale)), fmt.Sprint(v)})
+		x += 40
+	}
+	ch.AxisY = y
+	ch.Note, ch.NoteX = note, x
+	ch.Height = y + svgLegend
+	return ch
+}
+
+// npsChart returns a chart of r's detractors, passives and promoters as a
+// stacked bar.
+func npsChart(r Report) svgChart {
+	row := svgRow{Label: "NPS", Value: r.formatNPS()}
+	parts := []struct {
+		label string
+		n     int
+		col   color.RGBA
+	}{
+		{"detractors", r.Detractors, colorDetractor},
+		{"passives", r.Passives, colorPassive},
+		{"promoters", r.Promoters, colorPromoter},
+	}
+	total := r.Promoters + r.Passives + r.Detractors
+	row.Empty = total == 0
+	var ch svgChart
+	sum, x := 0, svgLabelW
+	for _, p := range parts {
+		label := fmt.Sprintf("%s %d", p.label, p.n)
+		if p.n > 0 {
+			row.Bars = append(row.Bars, svgBar{
+				X:     svgPlotW * float64(sum) / float64(total),
+				W:     svgPlotW * float64(p.n) / float64(total),
+				Fill:  cssColor(p.col),
+				Title: fmt.Sprintf("%s (%.0f%%)", label, 100*float64(p.n)/float64(total)),
+			})
+		}
+		sum += p.n
+		ch.Legend = append(ch.Legend, svgLegendItem{x, cssColor(p.col), label})
+		x += 120
+	}
+	ch.Rows = []svgRow{row}
+	ch.AxisY = svgRowH
+	ch.Height = svgRowH + svgLegend
+	return ch
+}
+
+// yesNoChart returns a chart of the answers to each Yes/No question, as
+// stacked bars of the share of Yes, No and blank answers.
+func yesNoChart(stats []YesNoStats) svgChart {
+	var ch svgChart
+	for i, st := range stats {
+		row := svgRow{
+			Y:     i * svgRowH,
+			Label: st.ID,
+			Title: st.Text,
+			Value: fmt.Sprintf("%.0f%% yes (n=%d)", st.Percent(st.Yes), st.Total()),
+			Empty: st.Total() == 0,
+		}
+		sum := 0
+		for _, p := range yesNoParts(st) {
+			if p.n > 0 {
+				row.Bars = append(row.Bars, svgBar{
+					X:     svgPlotW * float64(sum) / float64(st.Total()),
+					W:     svgPlotW * float64(p.n) / float64(st.Total()),
+					Fill:  cssColor(p.col),
+					Title: fmt.Sprintf("%s %d (%.0f%%)", p.label, p.n, st.Percent(p.n)),
+				})
+			}
+			sum += p.n
+		}
+		ch.Rows = append(ch.Rows, row)
+	}
+	ch.AxisY = len(ch.Rows) * svgRowH
+	x := svgLabelW
+	for _, p := range yesNoParts(YesNoStats{}) {
+		ch.Legend = append(ch.Legend, svgLegendItem{x, cssColor(p.col), p.label})
+		x += 120
+	}
+	ch.Height = ch.AxisY + svgLegend
+	return ch
+}
+
+// svgScale returns the horizontal offset of v on a bar scale from min to max
+// that is svgPlotW wide. Values outside the scale are clamped to it.
+func svgScale(v, min, max float64) float64 {
+	v = math.Max(min, math.Min(max, v))
+	return (v - min) / (max - min) * svgPlotW
+}
+
+// cssColor returns c in CSS hex notation.
+func cssColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// commentSection is the data passed to the "comments" HTML template.
+type commentSection struct {
+	Title    string
+	Comments map[string][]string
+}
+
+// Count returns the number of comments in the section.
+func (cs commentSection) Count() int {
+	n := 0
+	for _, list := range cs.Comments {
+		n += len(list)
+	}
+	return n
+}
+
+var htmlFuncs = template.FuncMap{
+	"avg":           formatAvg,
+	"nps":           Report.formatNPS,
+	"npsci":         Report.formatNPSCI,
+	"versions":      Report.formatVersions,
+	"spread":        formatSpread,
+	"scoreScale":    func() int { return catalog.scoreScale() },
+	"lagBuckets":    formatLagBuckets,
+	"inc":           func(i int) int { return i + 1 },
+	"questions":     reportQuestions,
+	"categories":    Report.shownCategories,
+	"allCategories": Report.categories,
+	"categoryChart": categoryChart,
+	"questionChart": questionChart,
+	"npsChart":      npsChart,
+	"yesNoChart":    yesNoChart,
+	"pct":           YesNoStats.Percent,
+	"comments": func(title string, m map[string][]string) commentSection {
+		return commentSection{title, m}
+	},
+}
+
+var htmlTmpl = template.Must(template.New("report").Funcs(htmlFuncs).Parse(`{{define "comments"}}
+{{- if .Comments}}
+<details>
+<summary>{{.Title}} comments ({{.Count}})</summary>
+{{- range $key, $list := .Comments}}
+<h4>{{$key}}</h4>
+<ul>
+{{- range $list}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</details>
+{{- end}}
+{{- end}}
+{{- define "chart"}}
+<svg class="chart" viewBox="0 0 ` + fmt.Sprint(svgWidth) + ` {{.Height}}" role="img">
+{{- range .Rows}}
+<g transform="translate(0 {{.Y}})">
+<text x="0" y="15">{{if .Title}}<title>{{.Title}}</title>{{end}}{{.Label}}</text>
+{{- if .Empty}}
+<text class="none" x="` + fmt.Sprint(svgLabelW) + `" y="15">no answers</text>
+{{- end}}
+{{- range .Bars}}
+<rect x="{{printf "%.1f" .X}}" y="3" width="{{printf "%.1f" .W}}" height="` + fmt.Sprint(svgBarH) + `" fill="{{.Fill}}" transform="translate(` + fmt.Sprint(svgLabelW) + ` 0)"><title>{{.Title}}</title></rect>
+{{- end}}
+{{- with .Whisker}}
+<path class="whisker" d="M{{printf "%.1f" .X0}} 11H{{printf "%.1f" .X1}}M{{printf "%.1f" .X0}} 6V16M{{printf "%.1f" .X1}} 6V16" transform="translate(` + fmt.Sprint(svgLabelW) + ` 0)"/>
+{{- end}}
+<text x="` + fmt.Sprint(svgValueX) + `" y="15">{{.Value}}</text>
+</g>
+{{- end}}
+{{- if .Axis}}
+<g class="axis" transform="translate(` + fmt.Sprint(svgLabelW) + ` {{.AxisY}})">
+<line x1="0" y1="0" x2="` + fmt.Sprint(svgPlotW) + `" y2="0"/>
+{{- range .Axis}}
+<line x1="{{printf "%.1f" .X}}" y1="0" x2="{{printf "%.1f" .X}}" y2="4"/>
+<text x="{{printf "%.1f" .X}}" y="16" text-anchor="middle">{{.Label}}</text>
+{{- end}}
+</g>
+{{- end}}
+{{- if .Legend}}
+<g transform="translate(0 {{.AxisY}})">
+{{- range .Legend}}
+<rect x="{{.X}}" y="6" width="10" height="10" fill="{{.Fill}}"/>
+<text x="{{.X}}" y="15" dx="14">{{.Label}}</text>
+{{- end}}
+{{- if .Note}}
+<text x="{{.NoteX}}" y="15">{{.Note}}</text>
+{{- end}}
+</g>
+{{- end}}
+</svg>
+{{- end}}
+{{- define "questions"}}
+{{- range .}}
+<tr class="question"><td title="{{.Text}}">{{.ID}}</td><td>{{avg .Mean .Count}}</td><td>{{.Count}} answered: {{range $i, $n := .Histogram}}{{if $i}}, {{end}}{{inc $i}}&rarr;{{$n}}{{end}}</td><td>{{spread .Spread .Count}}</td></tr>
+{{- end}}
+{{- end}}
+{{- define "body"}}
+<h3>Summary</h3>
+<table>
+<tr><th>Responses</th><td>{{.Responses}}</td><td></td><td></td></tr>
+{{- if .MixedVersions}}
+<tr class="mixed"><th>Versions</th><td colspan="3">{{versions .}} &ndash; mixed survey versions, answers normalised across them</td></tr>
+{{- end}}
+{{- range categories .}}
+<tr><th>{{.Label}}</th><td>{{avg .Avg .Count}}</td><td>{{.Count}} answered</td><td>{{spread .Spread .Count}}</td></tr>
+{{- template "questions" .Questions}}
+{{- end}}
+<tr><th>NPS</th><td>{{nps .}}</td><td>{{.Promoters}} promoters, {{.Passives}} passives, {{.Detractors}} detractors, {{.NPSNonRespondents}} unanswered</td><td>{{npsci .}}</td></tr>
+{{- with .ResponseLag}}{{if .Count}}
+<tr><th>Response lag</th><td>{{printf "%.1f" .Mean}} days</td><td>{{.Count}} dated: {{lagBuckets .Buckets}}</td><td>{{spread .Spread .Count}}</td></tr>
+{{- end}}{{end}}
+</table>
+<h3>Category averages (1-{{scoreScale}}) with 95% CI</h3>
+{{- template "chart" (categoryChart .)}}
+<h3>Answer distribution</h3>
+{{- template "chart" (questionChart (questions .))}}
+<h3>Net Promoter Score</h3>
+{{- template "chart" (npsChart .)}}
+{{- if .Readiness}}
+<h3>Readiness &amp; Impact</h3>
+<table>
+<tr><th></th><th>Yes</th><th>No</th><th>Blank</th><th></th></tr>
+{{- range .Readiness}}
+<tr><td title="{{.Text}}">{{.ID}}</td><td>{{printf "%.1f%%" (pct . .Yes)}}</td><td>{{printf "%.1f%%" (pct . .No)}}</td><td>{{printf "%.1f%%" (pct . .Blank)}}</td><td>{{.Total}} asked</td></tr>
+{{- end}}
+</table>
+{{- template "chart" (yesNoChart .Readiness)}}
+{{- end}}
+{{- if or .CurriculumComments .InstructorComments .EnvironmentComments .FacilityComments .OverallComments}}
+<h3>Comments</h3>
+{{- range allCategories .}}
+{{- template "comments" (comments .Label .Comments)}}
+{{- end}}
+{{- end}}
+{{- end}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Survey Report</title>
+<style>
+body { font-family: sans-serif; color: #222; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+h2 { border-bottom: 1px solid #ccc; margin-top: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 0.8em; text-align: left; border-bottom: 1px solid #e5e5e5; }
+tr.question td { color: #555; font-size: 0.9em; }
+tr.question td:first-child { padding-left: 2em; }
+tr.mixed td { color: #a60; }
+svg.chart { width: 100%; max-width: ` + fmt.Sprint(svgWidth) + `px; font-size: 12px; }
+svg.chart text { fill: #222; }
+svg.chart text.none { fill: #999; }
+svg.chart .axis line, svg.chart .whisker { stroke: #222; fill: none; }
+svg.chart .axis line { stroke: #999; }
+details { margin: 0.5em 0; }
+summary { cursor: pointer; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Survey Report</h1>
+{{- if .Groups}}
+<h2>{{.Title}}</h2>
+{{- end}}
+{{template "body" .}}
+{{- range .Groups}}
+<h2>{{.Title}}</h2>
+{{template "body" .}}
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// A RecordReader reads survey records from an input, in any input format.
+//
+// ReadRecord returns io.EOF when there are no more records. If a record is
+// malformed, it returns what it could read of the record along with a
+// *ParseError describing the first problem. Any other error means the input
+// could not be read.
+type RecordReader interface {
+	ReadRecord() (*Record, error)
+}
+
+// An inputFormat is a survey file format that records can be read from.
+type inputFormat struct {
+	Name       string
+	Extensions []string // File name extensions, such as ".csv".
+
+	// Sniff reports whether an input starting with head, which holds at
+	// most sniffLen bytes, looks like this format.
+	Sniff func(head []byte) bool
+
+	// Open returns a RecordReader reading from r, which is named name.
+	Open func(r io.Reader, name string) (RecordReader, error)
+}
+
+// sniffLen is the number of bytes of an input looked at to detect its format.
+const sniffLen = 4096
+
+// inputFormats are the supported input formats. An input is read in the
+// first format whose extensions match its name or, failing that, the first
+// that sniffs its content. Cookie-jar, the last, is the default.
+var inputFormats = []inputFormat{
+	{"xlsx", []string{".xlsx"}, sniffXLSX, openXLSX},
+	{"csv", []string{".csv"}, sniffCSV, openCSV},
+	{"cookie-jar", []string{".txt"}, func([]byte) bool { return true }, openCookieJar},
+}
+
+// openInput returns a RecordReader for r, which is named name, in the input
+// format chosen by name's extension or r's content.
+func openInput(r io.Reader, name string) (RecordReader, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, f := range inputFormats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f.Open(r, name)
+			}
+		}
+	}
+
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	for _, f := range inputFormats {
+		if f.Sniff(head) {
+			return f.Open(br, name)
+		}
+	}
+	return nil, fmt.Errorf("%s: unknown input format", name)
+}
+
+// openCookieJar returns a Decoder reading cookie-jar records from r.
+func openCookieJar(r io.Reader, name string) (RecordReader, error) {
+	dec := NewDecoder(r)
+	dec.Name = name
+	return dec, nil
+}
+
+// firstLine returns the first non-empty line of head.
+func firstLine(head []byte) []byte {
+	for _, line := range bytes.Split(head, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return line
+		}
+	}
+	return nil
+}
+
+// sniffCSV reports whether head looks like the start of a CSV file: a
+// header line of comma-separated names, rather than a cookie-jar key=value
+// line.
+func sniffCSV(head []byte) bool {
+	line := firstLine(head)
+	return bytes.IndexByte(line, ',') >= 0 && bytes.IndexByte(line, '=') < 0
+}
+
+// A tableReader reads records from rows of a table whose first row holds the
+// column headers, such as a CSV file or a spreadsheet. Each column header is
+// used as a cookie-jar key, so headers such as "Q2-07" match Survey fields
+// just as they do in cookie-jar files. Empty cells are left out of records.
+type tableReader struct {
+	name   string
+	header []string
+	record int
+
+	// next returns the next row and its line number, or io.EOF.
+	next func() (row []string, line int, err error)
+}
+
+// newTableReader returns a tableReader reading rows from next, after reading
+// the header row. A leading byte order mark, as written by spreadsheet
+// programs, is removed from the first header.
+func newTableReader(name string, next func() ([]string, int, error)) (*tableReader, error) {
+	header, _, err := next()
+	if err == io.EOF {
+		return &tableReader{name: name, next: next}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading header: %s", name, err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	return &tableReader{name: name, header: header, next: next}, nil
+}
+
+// ReadRecord reads the record in the next non-empty row.
+func (t *tableReader) ReadRecord() (*Record, error) {
+	for {
+		row, line, err := t.next()
+		if err != nil {
+			if perr, ok := err.(*ParseError); ok {
+				t.record++
+				perr.File, perr.Record = t.name, t.record
+				return &Record{File: t.name, Number: t.record}, perr
+			}
+			return nil, err
+		}
+		if isEmptyRow(row) {
+			continue
+		}
+
+		t.record++
+		rec := &Record{File: t.name, Number: t.record}
+		var firstErr error
+		for i, v := range row {
+			if v == "" {
+				continue
+			}
+			if i >= len(t.header) || t.header[i] == "" {
+				if firstErr == nil {
+					firstErr = rec.errorf(line, "", "value %q in column %d has no header", v, i+1)
+				}
+				continue
+			}
+			rec.Fields = append(rec.Fields, Field{Key: t.header[i], Value: v, Line: line})
+		}
+		return rec, firstErr
+	}
+}
+
+// isEmptyRow reports whether every cell of row is empty.
+func isEmptyRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// openCSV returns a RecordReader reading records from r in CSV format, with
+// a header row and one row per survey.
+func openCSV(r io.Reader, name string) (RecordReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	next := func() ([]string, int, error) {
+		row, err := cr.Read()
+		if perr, ok := err.(*csv.ParseError); ok {
+			return nil, 0, &ParseError{Line: perr.Line, Err: perr.Err}
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		line, _ := cr.FieldPos(0)
+		return row, line, nil
+	}
+	return newTableReader(name, next)
+}
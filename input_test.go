@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSniffCSV(t *testing.T) {
+	tests := []struct {
+		head string
+		csv  bool
+	}{
+		{"course,instructor,Q2-07\nRH124,Ann Lee,4\n", true},
+		{"\n\n  course,Q207\r\n", true},
+		{"\ufeffcourse,Q207\n", true},
+		{"course=RH124\nQ207=4\n", false},
+		{"Q403=Good labs, well paced\n", false},
+		{"course\nRH124\n", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := sniffCSV([]byte(tt.head)); got != tt.csv {
+			t.Errorf("sniffCSV(%q) = %v, want %v", tt.head, got, tt.csv)
+		}
+	}
+}
+
+func TestOpenInput(t *testing.T) {
+	const (
+		cookieJar = "course=RH124\nQ207=4\n=\n"
+		csv       = "course,Q207\nRH124,4\n"
+	)
+	xlsx := testXLSX(t, map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+<row r="1"><c r="A1" t="inlineStr"><is><t>course</t></is></c><c r="B1" t="inlineStr"><is><t>Q207</t></is></c></row>
+<row r="2"><c r="A2" t="inlineStr"><is><t>RH124</t></is></c><c r="B2"><v>4</v></c></row>
+</sheetData></worksheet>`})
+	want := []Field{{"course", "RH124", 0}, {"Q207", "4", 0}}
+
+	tests := []struct {
+		name, input string
+	}{
+		// By extension, whatever the content.
+		{"a.txt", cookieJar},
+		{"a.CSV", csv},
+		{"a.xlsx", xlsx},
+
+		// By content, if the extension is unknown.
+		{"-", cookieJar},
+		{"-", csv},
+		{"export.dat", xlsx},
+		{"-", "\ufeff" + csv},
+	}
+	for _, tt := range tests {
+		rr, err := openInput(strings.NewReader(tt.input), tt.name)
+		if err != nil {
+			t.Errorf("openInput(%q): %s", tt.name, err)
+			continue
+		}
+		rec, err := rr.ReadRecord()
+		if err != nil {
+			t.Errorf("openInput(%q): ReadRecord: %s", tt.name, err)
+			continue
+		}
+		var got []Field
+		for _, f := range rec.Fields {
+			got = append(got, Field{f.Key, f.Value, 0})
+		}
+		if !reflect.DeepEqual(got, want) || rec.File != tt.name {
+			t.Errorf("openInput(%q) reads %v from %q, want %v", tt.name, got, rec.File, want)
+		}
+	}
+}
+
+func TestOpenCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []result
+	}{
+		{
+			name:  "byte order mark and CRLF",
+			input: "\ufeffcourse,Q2-07\r\nRH124,4\r\n",
+			want:  []result{{fields: []Field{{"course", "RH124", 2}, {"Q2-07", "4", 2}}}},
+		},
+		{
+			name:  "empty cells and rows",
+			input: "course,instructor,Q207\nRH124,,5\n,,\n\nRH134,\"Lee, Ann\",\n",
+			want: []result{
+				{fields: []Field{{"course", "RH124", 2}, {"Q207", "5", 2}}},
+				{fields: []Field{{"course", "RH134", 5}, {"instructor", "Lee, Ann", 5}}},
+			},
+		},
+		{
+			name:  "blank header cells",
+			input: "course,,Q207\nRH124,stray,4\nRH134,,3,extra\n",
+			want: []result{
+				{fields: []Field{{"course", "RH124", 2}, {"Q207", "4", 2}}, err: true},
+				{fields: []Field{{"course", "RH134", 3}, {"Q207", "3", 3}}, err: true},
+			},
+		},
+		{
+			name:  "header only",
+			input: "course,Q207\n",
+		},
+	}
+	for _, tt := range tests {
+		rr, err := openCSV(strings.NewReader(tt.input), "a.csv")
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		checkRecords(t, tt.name, rr, tt.want)
+	}
+}
+
+// A result is a record a RecordReader is expected to return.
+type result struct {
+	fields []Field
+	err    bool // Whether a *ParseError is returned with the record.
+}
+
+// checkRecords checks that rr returns the records in want, then io.EOF.
+func checkRecords(t *testing.T, name string, rr RecordReader, want []result) {
+	t.Helper()
+	for i, want := range want {
+		rec, err := rr.ReadRecord()
+		if _, isParseErr := err.(*ParseError); err != nil && !isParseErr {
+			t.Fatalf("%s: record %d: %s", name, i+1, err)
+		}
+		if (err != nil) != want.err {
+			t.Errorf("%s: record %d: error %v, want error: %v", name, i+1, err, want.err)
+		}
+		if rec.Number != i+1 {
+			t.Errorf("%s: record %d: numbered %d", name, i+1, rec.Number)
+		}
+		if !reflect.DeepEqual(rec.Fields, want.fields) {
+			t.Errorf("%s: record %d: fields %v, want %v", name, i+1, rec.Fields, want.fields)
+		}
+	}
+	if _, err := rr.ReadRecord(); err != io.EOF {
+		t.Errorf("%s: after the last record: error %v, want io.EOF", name, err)
+	}
+}
@@ -2,46 +2,109 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
-	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"sort"
 	"strings"
 
-	"github.com/gonum/stat"
 	"github.com/hashicorp/logutils"
 )
 
 // NPS returns the NPS score given numbers of promoters (>= 9/10),
-// passives (>= 7/10) & detractors (>= 0/10).
+// passives (>= 7/10) & detractors (>= 1/10). ok is false if there are no
+// respondents at all, in which case the score is not available.
 //
 // The score can range from -100 (everybody is a detractor) to 100 (everybody
 // is a promoter). An NPS that is positive (i.e., greater than zero) is felt to
 // be good, and an NPS of +50 is excellent.
 // Ref: https://en.wikipedia.org/wiki/Net_Promoter
-func NPS(promoters, passives, detractors int) float64 {
-	return float64(promoters-detractors) / float64(promoters+passives+detractors) * 100
+func NPS(promoters, passives, detractors int) (score float64, ok bool) {
+	total := promoters + passives + detractors
+	if total == 0 {
+		return 0, false
+	}
+	return float64(promoters-detractors) / float64(total) * 100, true
 }
 
 // Report represents the final average scores and comments result of evaluating Surveys.
 type Report struct {
+	// Group holds the values of the fields the surveys were grouped by,
+	// keyed by field name. It is empty for a report over all surveys.
+	Group map[string]string
+
 	Responses int
 
-	CurriculumAvg  float64
-	InstructorAvg  float64
-	EnvironmentAvg float64
-	OverallAvg     float64
-	NPS            float64
+	// SurveyVersions holds the number of responses of each survey version
+	// (see Catalog.versionLabel). A report over more than one version mixes
+	// answers normalised across them; see MixedVersions.
+	SurveyVersions map[string]int
+
+	// Category averages are the mean, over learners who rated at least one
+	// question in the category, of each learner's average answer. The
+	// matching Count is the number of such learners; an average is 0 if its
+	// Count is 0. Categories only count learners whose modality they apply
+	// to, so that Environment covers virtual classes and Facility onsite
+	// ones.
+	CurriculumAvg    float64
+	CurriculumCount  int
+	InstructorAvg    float64
+	InstructorCount  int
+	EnvironmentAvg   float64
+	EnvironmentCount int
+	FacilityAvg      float64
+	FacilityCount    int
+	OverallAvg       float64
+	OverallCount     int
+
+	// Spread of the learners' averages in each category.
+	CurriculumSpread  Spread
+	InstructorSpread  Spread
+	EnvironmentSpread Spread
+	FacilitySpread    Spread
+	OverallSpread     Spread
+
+	// Per-question breakdowns of each category, in survey order.
+	CurriculumQuestions  []QuestionStats
+	InstructorQuestions  []QuestionStats
+	EnvironmentQuestions []QuestionStats
+	FacilityQuestions    []QuestionStats
+	OverallQuestions     []QuestionStats
+
+	// NPS is only computed over learners who answered the recommendation
+	// question (Q410) on its 1-10 scale. NPSAvailable is false, and NPS is 0,
+	// if nobody did.
+	NPS               float64
+	NPSAvailable      bool
+	Promoters         int
+	Passives          int
+	Detractors        int
+	NPSNonRespondents int
+
+	// NPSCILow and NPSCIHigh bound a bootstrapped 95% confidence interval
+	// for the NPS. Both are 0 if the NPS is not available.
+	NPSCILow  float64
+	NPSCIHigh float64
+
+	// Readiness holds the answers to each Yes/No question, reported under
+	// Readiness & Impact.
+	Readiness []YesNoStats
+
+	// ResponseLag is the distribution of days from the start of each class
+	// to submission of its survey.
+	ResponseLag LagStats
 
 	CurriculumComments  map[string][]string
 	InstructorComments  map[string][]string
 	EnvironmentComments map[string][]string
+	FacilityComments    map[string][]string
 	OverallComments     map[string][]string
+
+	// Groups holds a Report for each group, if the surveys were grouped.
+	Groups []Report
 }
 
 // Survey represents a course survey response for an individual learner.
@@ -60,45 +123,45 @@ type Survey struct {
 	Q1508 string // Do you want to be contacted by Red Hat to discuss your training experience?
 
 	// CURRICULUM (5 = strongly agree, 1 = strongly disagree, N/A)
-	Q207 int    `json:",string"` // The student guide was accurate and had the right amount of detail
-	Q208 int    `json:",string"` // The course had a logical structure and covered relevant subject matter
-	Q209 int    `json:",string"` // The labs adequately reinforced the topics discussed in class
-	Q210 int    `json:",string"` // The course allowed sufficient time to adequately cover the material
+	Q207 Answer // The student guide was accurate and had the right amount of detail
+	Q208 Answer // The course had a logical structure and covered relevant subject matter
+	Q209 Answer // The labs adequately reinforced the topics discussed in class
+	Q210 Answer // The course allowed sufficient time to adequately cover the material
 	Q508 string // Comments: Curriculum
 
 	// INSTRUCTOR (5 = strongly agree, 1 = strongly disagree, N/A)
-	Q306 int    `json:",string"` // The instructor demonstrated expertise in the topics taught
-	Q307 int    `json:",string"` // The instructor showed evidence of strong preparation
-	Q308 int    `json:",string"` // The instructor made concepts and tasks clear
-	Q320 int    `json:",string"` // The instructor effectively managed classroom interaction and student participation
-	Q310 int    `json:",string"` // The instructor provided accurate and helpful answers to questions
+	Q306 Answer // The instructor demonstrated expertise in the topics taught
+	Q307 Answer // The instructor showed evidence of strong preparation
+	Q308 Answer // The instructor made concepts and tasks clear
+	Q320 Answer // The instructor effectively managed classroom interaction and student participation
+	Q310 Answer // The instructor provided accurate and helpful answers to questions
 	Q318 string // Comments: Instructor
 
-	// ONSITE ONLY?
+	// ONSITE ONLY
 	// CLASSROOM FACILITY (5 = strongly agree, 1 = strongly disagree, N/A)
-	//Q611 int `json:",string"` // The computers and network were sufficient for the class
-	//Q609 int `json:",string"` // The room and facility were comfortable
-	//Q612 int `json:",string"` // The facility staff were hospitable
-	//Q610 string //  Comments: Facility
+	Q611 Answer // The computers and network were sufficient for the class
+	Q609 Answer // The room and facility were comfortable
+	Q612 Answer // The facility staff were hospitable
+	Q610 string // Comments: Facility
 
-	// VT ONLY?
+	// VT ONLY
 	// LEARNING ENVIRONMENT (5 = strongly agree, 1 = strongly disagree, N/A)
 	Q1901 string // I tested my connection and systems prior to the start of the course
-	Q1002 int    `json:",string"` // Pre-class support was effective, responsive and accessible
-	Q1003 int    `json:",string"` // The performance of the audio conferencing system was adequate
-	Q1004 int    `json:",string"` // The performance of the web conferencing system was adequate
-	Q1005 int    `json:",string"` // The performance of lab exercises was adequate
+	Q1002 Answer // Pre-class support was effective, responsive and accessible
+	Q1003 Answer // The performance of the audio conferencing system was adequate
+	Q1004 Answer // The performance of the web conferencing system was adequate
+	Q1005 Answer // The performance of lab exercises was adequate
 	Q1907 string // Comments: Learning Environment
 
 	// OVERALL
 	// Please tell us your overall rating of this training event
 	//(5 = strongly positive, 1 = strongly negative, N/A)
-	Q311 int `json:",string"`
+	Q311 Answer
 
 	// How likely would you be to recommend Red Hat Training to a friend or
 	// colleague in need of similar training?
 	// (10 extremely likely, 1 extremely unlikely, N/A)
-	Q410 int    `json:",string"`
+	Q410 Answer
 	Q403 string // Comments: Overall
 
 	// ADDITIONAL QUESTIONS (Yes / No)
@@ -116,163 +179,494 @@ type Survey struct {
 	Q1401 string // Tell us about your company&#39;s or organization&#39;s current relationship to Red Hat (Select the item that most closely matches)
 	Q1701 string // What is the primary reason you are taking this Red Hat training?
 
-	StartDate  string `json:"start_date"`
+	StartDate  Date `json:"start_date"`
 	Subscript  string
-	SurveyDate string
+	SurveyDate Date
 	SurveyVer  string `json:"survey_ver"`
+
+	// Extra holds the answers to questions that have no field above, such
+	// as those added to the catalog since, keyed by question ID.
+	Extra map[string]string `json:",omitempty"`
+}
+
+// commands maps subcommand names to their implementations. Each is passed
+// the command-line arguments following its name.
+var commands = map[string]func(args []string){
+	"catalog":  runCatalog,
+	"compare":  runCompare,
+	"history":  runHistory,
+	"trend":    runTrend,
+	"save":     runSave,
+	"validate": runValidate,
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			setupLogging(false)
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
 	debug := flag.Bool("d", false, "print debugging output")
+	names := flag.Bool("n", false, "attach learner names to comments")
+	strict := flag.Bool("strict", false, "fail on the first malformed record instead of skipping it")
+	format := flag.String("f", "text", "output format ("+strings.Join(Formats(), ", ")+")")
+	responses := flag.Bool("responses", false, "with -f csv, write one row per response instead of the report")
+	catalogFile := catalogFlag(flag.CommandLine)
+	tz := tzFlag(flag.CommandLine)
+	groupBy := flag.String("group-by", "", "comma-separated fields to group reports by ("+strings.Join(GroupFields(), ", ")+")")
+	var filters filterFlag
+	flag.Var(&filters, "filter", "only report on surveys matching `expr`, such as 'course=RH124 AND start_date>=2016-09-01' (repeatable)")
+	perClass := flag.Bool("per-class", false, "write one report per class delivery, and an index of them, to the -o directory")
+	outDir := flag.String("o", ".", "output directory for -per-class")
+	anonymise := flag.Bool("anonymise", false, "drop learner names and emails, or hash them with -salt, and redact emails, phone numbers and names from comments")
+	salt := flag.String("salt", "", "with -anonymise, hash learner names and emails with this salt instead of dropping them (default from $"+saltEnv+")")
+	namesFile := flag.String("redact-names", "", "with -anonymise, also redact the names listed in `file`, one per line, from comments")
 	flag.Parse()
 
-	// Set up levelled logging.
+	setupLogging(*debug)
+	useCatalog(*catalogFile)
+	useTimeZone(*tz)
+
+	render, err := LookupRenderer(*format)
+	if err != nil {
+		log.Fatalf("[INFO] %s\n", err)
+	}
+	if *responses && *format != "csv" {
+		log.Fatalf("[INFO] -responses requires -f csv\n")
+	}
+	if *responses && *perClass {
+		log.Fatalf("[INFO] -responses cannot be used with -per-class\n")
+	}
+	if *namesFile != "" && !*anonymise {
+		log.Fatalf("[INFO] -redact-names requires -anonymise\n")
+	}
+	by, err := ParseGroupBy(*groupBy)
+	if err != nil {
+		log.Fatalf("[INFO] %s\n", err)
+	}
+
+	surveys, skipped, err := readSurveys(flag.Args(), *strict)
+	if err != nil {
+		log.Fatalf("[INFO] Error reading surveys: %s\n", err)
+	}
+	printSkipped(skipped)
+	surveys = filterSurveys(surveys, allFilters(filters))
+	if *anonymise {
+		// The salt is read from the environment here rather than as the
+		// flag's default, so that -h does not print it.
+		a := Anonymiser{Salt: *salt}
+		if a.Salt == "" {
+			a.Salt = os.Getenv(saltEnv)
+		}
+		if *namesFile != "" {
+			if a.Names, err = readNames(*namesFile); err != nil {
+				log.Fatalf("[INFO] Error reading names to redact: %s\n", err)
+			}
+		}
+		a.Anonymise(surveys)
+	}
+
+	if *responses {
+		if err := WriteSurveysCSV(os.Stdout, surveys); err != nil {
+			log.Fatalf("[INFO] Error writing responses: %s\n", err)
+		}
+		return
+	}
+	if *perClass {
+		index, err := WritePerClass(*outDir, *format, surveys, *names, by)
+		if err != nil {
+			log.Fatalf("[INFO] Error writing class reports: %s\n", err)
+		}
+		fmt.Printf("Wrote %d class report(s) and %s to %s\n", len(index), indexFile, *outDir)
+		return
+	}
+	if err := render(os.Stdout, NewGroupedReport(surveys, *names, by)); err != nil {
+		log.Fatalf("[INFO] Error rendering report: %s\n", err)
+	}
+}
+
+// setupLogging sets up levelled logging, including DEBUG messages if debug is
+// true.
+func setupLogging(debug bool) {
 	filter := &logutils.LevelFilter{
 		Levels:   []logutils.LogLevel{"DEBUG", "INFO"},
 		MinLevel: logutils.LogLevel("INFO"),
 		Writer:   os.Stdout,
 	}
-	if *debug {
+	if debug {
 		filter.MinLevel = logutils.LogLevel("DEBUG")
 	}
 	log.SetOutput(filter)
+}
 
-	// An io.Reader that we will read our survey data from (os.Stdin by
-	// default).
-	var r io.Reader
-	r = os.Stdin
-
-	// If file arguments are passed on the command line, use these files as
-	// source of survey data.
-	if flag.NArg() > 0 {
-		var files []io.Reader
-		for _, filename := range flag.Args() {
-			f, err := os.Open(filename)
+// readSurveys decodes the surveys in the named files, or in os.Stdin if no
+// files are given. Malformed records are left out of surveys and their errors
+// returned in skipped, unless strict is true, in which case the first one is
+// returned as err.
+func readSurveys(filenames []string, strict bool) (surveys []*Survey, skipped []*ParseError, err error) {
+	decode := func(rr RecordReader) error {
+		for {
+			var s Survey
+			err := decodeSurvey(rr, &s)
+			if err == io.EOF {
+				return nil
+			}
+			if perr, ok := err.(*ParseError); ok {
+				if strict {
+					return perr
+				}
+				log.Printf("[DEBUG] Skipping record: %s\n", perr)
+				skipped = append(skipped, perr)
+				continue
+			}
 			if err != nil {
-				log.Fatalf("[INFO] Error opening file: %s\n", err)
+				return err
 			}
-			defer f.Close()
-
-			files = append(files, f)
+			log.Printf("[DEBUG] Survey %d: %+v\n", len(surveys)+1, s)
+			surveys = append(surveys, &s)
 		}
+	}
 
-		r = io.MultiReader(files...)
+	if err := eachInput(filenames, decode); err != nil {
+		return nil, nil, err
 	}
+	return surveys, skipped, nil
+}
 
-	surveyBytes, err := TxtToJSON(r)
-	if err != nil {
-		log.Fatalf("[INFO] Error converting txt to JSON: %s\n", err)
+// printSkipped prints a summary of the records skipped by readSurveys to
+// standard error.
+func printSkipped(skipped []*ParseError) {
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Skipped %d malformed record(s):\n", len(skipped))
+	for _, e := range skipped {
+		fmt.Fprintf(os.Stderr, "  %s\n", e)
 	}
-	log.Printf("[DEBUG] surveyBytes:\n%s\n", surveyBytes)
+}
 
-	var surveys []*Survey
-	dec := json.NewDecoder(bytes.NewReader(surveyBytes))
-	for dec.More() {
-		var s Survey
-		err := dec.Decode(&s)
+// eachInput calls fn with a RecordReader for each of the named files in
+// turn, or for os.Stdin if no files are given. Each input is read in the
+// format chosen by openInput. It stops at the first error.
+func eachInput(filenames []string, fn func(RecordReader) error) error {
+	if len(filenames) == 0 {
+		rr, err := openInput(os.Stdin, "<stdin>")
 		if err != nil {
-			log.Printf("[DEBUG] Decode error: %s\n", err)
+			return err
 		}
-		surveys = append(surveys, &s)
+		return fn(rr)
 	}
 
-	fmt.Printf(NewReport(surveys).String())
+	for _, filename := range filenames {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		rr, err := openInput(f, filename)
+		if err == nil {
+			err = fn(rr)
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // NewReport returns a new Report from a slice of Surveys.
-func NewReport(surveys []*Survey) Report {
-	var curriculumAvgsSum,
-		instructorAvgsSum,
-		environmentAvgsSum,
-		overallAvgsSum float64
-	var promoters, passives, detractors int
+//
+// Each survey is scored against the questions of its version in the catalog,
+// in the categories that apply to its modality. Instructor comments are keyed
+// by instructor, all other comments by course. If names is true, each
+// comment is followed by the learner's name.
+func NewReport(surveys []*Survey, names bool) Report {
+	scores := make(map[string]sample)
+	comments := make(map[string]map[string][]string)
+	for _, c := range catalogCategories {
+		scores[c] = categorySample(c, surveys)
+		comments[c] = make(map[string][]string)
+	}
+	var promoters, passives, detractors, nonRespondents int
 
 	for _, s := range surveys {
-		curriculumAvgsSum += float64(s.Q207+s.Q208+s.Q209+s.Q210) / 4.0
-		instructorAvgsSum += float64(s.Q306+s.Q307+s.Q308+s.Q320) / 4.0
-		environmentAvgsSum += float64(s.Q1002+s.Q1003+s.Q1004+s.Q1005) / 4.0
-		overallAvgsSum += float64(s.Q311)
+		name := ""
+		if names {
+			name = s.Name
+		}
+		v := catalog.version(s.SurveyVer)
+		for _, c := range v.Categories {
+			if !c.applies(s) {
+				continue
+			}
+			key := s.Course
+			if c.Name == "instructor" {
+				key = s.Instructor
+			}
+			if c.Comment != "" {
+				addComment(comments[c.Name], key, s.value(c.Comment), name)
+			}
+		}
 
-		// Tally NPS variables.
+		// Tally NPS variables. Blank, N/A and out-of-range answers are
+		// not counted as detractors.
+		var a Answer
+		if q, ok := v.npsQuestion(); ok {
+			a = s.answer(q)
+		}
 		switch {
-		case s.Q410 >= 9:
+		case !a.Valid || a.Value < 1 || a.Value > 10:
+			nonRespondents++
+		case a.Value >= 9:
 			promoters++
-		case s.Q410 >= 7:
+		case a.Value >= 7:
 			passives++
-		case s.Q410 >= 0:
+		default:
 			detractors++
 		}
 	}
 
-	//mean, std := stat.MeanStdDev([]float64{}, nil)
-	stat.MeanStdDev([]float64{}, nil)
-
-	n := float64(len(surveys))
+	questions := func(category string) []QuestionStats {
+		applicable := catalog.applicable(category, surveys)
+		if len(applicable) == 0 && len(surveys) > 0 {
+			return nil
+		}
+		return newQuestionStats(catalog.questions(category, applicable), applicable)
+	}
+	spread := func(category string) Spread {
+		return newSpread(scores[category]).clamp(1, float64(catalog.categoryScale(category)))
+	}
+	nps, npsOK := NPS(promoters, passives, detractors)
+	npsLow, npsHigh, _ := bootstrapNPS(promoters, passives, detractors)
 	return Report{
-		Responses:      int(n),
-		NPS:            NPS(promoters, passives, detractors),
-		CurriculumAvg:  curriculumAvgsSum / n,
-		InstructorAvg:  instructorAvgsSum / n,
-		EnvironmentAvg: environmentAvgsSum / n,
-		OverallAvg:     overallAvgsSum / n,
+		Responses:      len(surveys),
+		SurveyVersions: catalog.SurveyVersions(surveys),
+
+		NPS:               nps,
+		NPSAvailable:      npsOK,
+		Promoters:         promoters,
+		Passives:          passives,
+		Detractors:        detractors,
+		NPSNonRespondents: nonRespondents,
+		NPSCILow:          npsLow,
+		NPSCIHigh:         npsHigh,
+
+		Readiness:   newYesNoStats(catalog.yesNoQuestions(surveys), surveys),
+		ResponseLag: newLagStats(surveys),
+
+		CurriculumAvg:    scores["curriculum"].mean(),
+		CurriculumCount:  len(scores["curriculum"]),
+		InstructorAvg:    scores["instructor"].mean(),
+		InstructorCount:  len(scores["instructor"]),
+		EnvironmentAvg:   scores["environment"].mean(),
+		EnvironmentCount: len(scores["environment"]),
+		FacilityAvg:      scores["facility"].mean(),
+		FacilityCount:    len(scores["facility"]),
+		OverallAvg:       scores["overall"].mean(),
+		OverallCount:     len(scores["overall"]),
+
+		CurriculumSpread:  spread("curriculum"),
+		InstructorSpread:  spread("instructor"),
+		EnvironmentSpread: spread("environment"),
+		FacilitySpread:    spread("facility"),
+		OverallSpread:     spread("overall"),
+
+		CurriculumQuestions:  questions("curriculum"),
+		InstructorQuestions:  questions("instructor"),
+		EnvironmentQuestions: questions("environment"),
+		FacilityQuestions:    questions("facility"),
+		OverallQuestions:     questions("overall"),
+
+		CurriculumComments:  comments["curriculum"],
+		InstructorComments:  comments["instructor"],
+		EnvironmentComments: comments["environment"],
+		FacilityComments:    comments["facility"],
+		OverallComments:     comments["overall"],
 	}
 }
 
-// String returns a string containing the Report's data.
-func (r Report) String() string {
-	return fmt.Sprintf("%-11s %3d\n%-11s %6.2f\n%-11s %6.2f\n%-11s %6.2f\n%-11s %6.2f\n%-11s %6.2f\n",
-		"Responses", r.Responses,
-		"Curriculum", r.CurriculumAvg,
-		"Instructor", r.InstructorAvg,
-		"Environment", r.EnvironmentAvg,
-		"Overall", r.OverallAvg,
-		"NPS", r.NPS,
-	)
+// addComment appends comment to m under key, followed by the learner's name
+// if one is given. Blank comments are ignored.
+func addComment(m map[string][]string, key, comment, name string) {
+	comment = strings.TrimSpace(comment)
+	if comment == "" {
+		return
+	}
+	if key == "" {
+		key = "(unknown)"
+	}
+	if name != "" {
+		comment = fmt.Sprintf("%s (%s)", comment, name)
+	}
+	m[key] = append(m[key], comment)
 }
 
-// TxtToJSON converts the native .txt survey format to JSON, for use as a
-// convenient intermediate representation before ultimate unmarshalling to a
-// Survey struct.
-func TxtToJSON(r io.Reader) ([]byte, error) {
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String returns a string containing the Report's data, followed by that of
+// each of its Groups.
+func (r Report) String() string {
 	var buf bytes.Buffer
+	if len(r.Groups) > 0 || len(r.Group) > 0 {
+		fmt.Fprintf(&buf, "=== %s ===\n", r.Title())
+	}
+	fmt.Fprintf(&buf, "%-11s %3d\n", "Responses", r.Responses)
+	if r.MixedVersions() {
+		writeLine(&buf, "%-11s %s  (mixed: answers normalised across versions)", "Versions", r.formatVersions())
+	}
+	for _, c := range r.shownCategories() {
+		writeScore(&buf, c.Label, c.Avg, c.Count, c.Spread)
+		writeQuestions(&buf, c.Questions)
+	}
+	writeLine(&buf, "%-11s %6s  (%d promoters, %d passives, %d detractors, %d unanswered)  %s",
+		"NPS", r.formatNPS(), r.Promoters, r.Passives, r.Detractors, r.NPSNonRespondents, r.formatNPSCI())
+	writeLag(&buf, r.ResponseLag)
+	writeReadiness(&buf, r.Readiness)
 
-	// Begin a new JSON object.
-	buf.Write([]byte("{\n"))
+	for _, c := range r.categories() {
+		writeComments(&buf, c.Label, c.Comments)
+	}
 
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Text()
+	for _, g := range r.Groups {
+		fmt.Fprintf(&buf, "\n%s", g)
+	}
 
-		// Validate input. Every .txt survey line should have an "=".
-		if !strings.Contains(line, "=") {
-			return buf.Bytes(), errors.New("invalid input")
-		}
+	return buf.String()
+}
 
-		// Survey delimiter. End last JSON object and begin a new one.
-		if line == "=" {
-			buf.Write([]byte("}\n{\n"))
-			continue
-		}
+// MixedVersions reports whether r covers surveys of more than one catalog
+// version, so that its scores compare answers across survey revisions.
+func (r Report) MixedVersions() bool {
+	return len(r.SurveyVersions) > 1
+}
 
-		// Data lines.
-		// Remove dash from question name (Q12-15 → Q1215) for
-		// unmarshalling, since idiomatic Go variable names don't use
-		// dashes.
-		question := strings.Split(line, "=")
-		question[0] = strings.Replace(question[0], "-", "", -1)
-		question[1] = strings.Replace(question[1], "\"", "\\\"", -1)
-		line = fmt.Sprintf("  \"%s\": \"%s\",\n", question[0], question[1])
-
-		// survey_ver is (always?) the last field for each survey
-		// record. Do not print a trailing comma.
-		if strings.Contains(line, "survey_ver") {
-			line = strings.Replace(line, ",\n", "\n", 1)
-		}
-		buf.Write([]byte(line))
+// formatVersions formats the Report's survey versions and their numbers of
+// responses, such as "1 (n=4), 2 (n=12)".
+func (r Report) formatVersions() string {
+	var parts []string
+	for _, ver := range sortedKeys(r.SurveyVersions) {
+		parts = append(parts, fmt.Sprintf("%s (n=%d)", ver, r.SurveyVersions[ver]))
 	}
+	return strings.Join(parts, ", ")
+}
 
-	// Remove extraneous trailing brace.
-	jsonBytes := bytes.TrimRight(buf.Bytes(), "{\n")
+// formatAvg formats a category average to two decimal places, or as "n/a"
+// if nobody answered the category.
+func formatAvg(avg float64, count int) string {
+	if count == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f", avg)
+}
 
-	return jsonBytes, nil
+// formatNPS formats the Report's NPS to two decimal places, or as "n/a" if
+// it is not available.
+func (r Report) formatNPS() string {
+	if !r.NPSAvailable {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f", r.NPS)
+}
+
+// formatSpread formats the standard deviation, median and confidence interval
+// of a sample of count values. Parts that cannot be computed for so few values
+// are left out.
+func formatSpread(sp Spread, count int) string {
+	switch {
+	case count == 0:
+		return ""
+	case count == 1:
+		return fmt.Sprintf("median %.2f", sp.Median)
+	}
+	return fmt.Sprintf("sd %.2f  median %.2f  95%% CI [%.2f, %.2f]", sp.StdDev, sp.Median, sp.CILow, sp.CIHigh)
+}
+
+// formatNPSCI formats the Report's NPS confidence interval, or returns "" if
+// the NPS is not available.
+func (r Report) formatNPSCI() string {
+	if !r.NPSAvailable {
+		return ""
+	}
+	return fmt.Sprintf("95%% CI [%.2f, %.2f]", r.NPSCILow, r.NPSCIHigh)
+}
+
+// writeLine writes a formatted line to w, without trailing spaces.
+func writeLine(w io.Writer, format string, args ...interface{}) {
+	fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf(format, args...), " "))
+}
+
+// writeScore writes an aligned line giving a category's average, the number
+// of learners it is over, and their spread.
+func writeScore(w io.Writer, label string, avg float64, count int, sp Spread) {
+	writeLine(w, "%-11s %6s  (n=%d)  %s", label, formatAvg(avg, count), count, formatSpread(sp, count))
+}
+
+// writeQuestions writes an indented line for each question in qs, giving
+// its mean, count, the number of learners who gave each answer and the
+// spread of the answers.
+func writeQuestions(w io.Writer, qs []QuestionStats) {
+	for _, q := range qs {
+		writeLine(w, "  %-9s %6s  (n=%d)  %v  %s", q.ID, formatAvg(q.Mean, q.Count), q.Count, q.Histogram, formatSpread(q.Spread, q.Count))
+	}
+}
+
+// writeLag writes aligned lines giving the mean response lag, its spread
+// and the number of surveys in each lag bucket. Nothing is written if no
+// survey has a lag.
+func writeLag(w io.Writer, st LagStats) {
+	if st.Count == 0 {
+		return
+	}
+	writeLine(w, "%-11s %6.1f  (n=%d)  %s", "Lag (days)", st.Mean, st.Count, formatSpread(st.Spread, st.Count))
+	writeLine(w, "  %s", formatLagBuckets(st.Buckets))
+}
+
+// formatLagBuckets formats the number of surveys in each lag bucket, such as
+// "same day 3, 1 day 5, 2-3 days 0".
+func formatLagBuckets(buckets []LagBucket) string {
+	var parts []string
+	for _, b := range buckets {
+		parts = append(parts, fmt.Sprintf("%s %d", b.Label, b.Count))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeReadiness writes a titled table of the percentage of learners who
+// answered Yes, No or nothing to each Yes/No question. Nothing is written if
+// there are no such questions.
+func writeReadiness(w io.Writer, stats []YesNoStats) {
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%-18s %6s %6s %6s\n", "Readiness & Impact", "Yes", "No", "Blank")
+	for _, st := range stats {
+		writeLine(w, "  %-16s %5.1f%% %5.1f%% %5.1f%%  (n=%d)",
+			st.ID, st.Percent(st.Yes), st.Percent(st.No), st.Percent(st.Blank), st.Total())
+	}
+}
+
+// writeComments writes a titled, indented list of comments to w, grouped by
+// key. Nothing is written if there are no comments.
+func writeComments(w io.Writer, title string, comments map[string][]string) {
+	if len(comments) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s comments\n", title)
+	for _, key := range sortedKeys(comments) {
+		fmt.Fprintf(w, "  %s\n", key)
+		for _, c := range comments[key] {
+			fmt.Fprintf(w, "    - %s\n", c)
+		}
+	}
 }
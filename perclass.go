@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// extensions maps output formats to the file name extension of reports
+// written in them.
+var extensions = map[string]string{
+	"csv":  ".csv",
+	"html": ".html",
+	"json": ".json",
+	"png":  ".png",
+	"text": ".txt",
+}
+
+// indexFile is the name of the index written alongside per-class reports.
+const indexFile = "index.csv"
+
+// A Delivery identifies one delivery of a course for per-class reports. It
+// is finer than a Class, since the same course may be delivered in several
+// versions or modalities by one instructor on the same day.
+type Delivery struct {
+	Course     string
+	CourseVer  string
+	Instructor string
+	StartDate  string
+	Modality   string
+}
+
+// deliveryOf returns the Delivery s was a response to.
+func deliveryOf(s *Survey) Delivery {
+	return Delivery{
+		Course:     s.Course,
+		CourseVer:  s.CourseVer,
+		Instructor: s.Instructor,
+		StartDate:  s.StartDate.Day(),
+		Modality:   s.Modality,
+	}
+}
+
+// group returns d as the Group of its report, keyed by field name as in
+// -group-by.
+func (d Delivery) group() map[string]string {
+	return map[string]string{
+		"course":     d.Course,
+		"course_ver": d.CourseVer,
+		"instructor": d.Instructor,
+		"start_date": d.StartDate,
+		"modality":   d.Modality,
+	}
+}
+
+// filename returns the name of the file d's report is written to, with the
+// given extension. Like Class.filename, it is readable and ends with a hash
+// of the exact delivery, so it is the same every time the delivery is
+// reported on.
+func (d Delivery) filename(ext string) string {
+	h := sha1.Sum([]byte(strings.Join([]string{d.Course, d.CourseVer, d.Instructor, d.StartDate, d.Modality}, "\x00")))
+	return fmt.Sprintf("%s_%s_%s_%s_%s_%x%s",
+		slug(d.StartDate), slug(d.Course), slug(d.CourseVer), slug(d.Instructor), slug(d.Modality), h[:4], ext)
+}
+
+// A ClassReport is an entry of the index of per-class reports.
+type ClassReport struct {
+	Delivery
+	File      string // Name of the report file, relative to the output directory.
+	Responses int
+}
+
+// WritePerClass writes a report in the given format for each course delivery
+// in surveys to dir, creating it if needed, followed by an index of the
+// reports. Each report is grouped by the by fields, as for
+// NewGroupedReport. It returns the index entries, ordered by start date,
+// course and instructor.
+func WritePerClass(dir, format string, surveys []*Survey, names bool, by []string) ([]ClassReport, error) {
+	render, err := LookupRenderer(format)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	byDelivery := make(map[Delivery][]*Survey)
+	var deliveries []Delivery
+	for _, s := range surveys {
+		d := deliveryOf(s)
+		if _, ok := byDelivery[d]; !ok {
+			deliveries = append(deliveries, d)
+		}
+		byDelivery[d] = append(byDelivery[d], s)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		a, b := deliveries[i], deliveries[j]
+		if a.StartDate != b.StartDate {
+			return a.StartDate < b.StartDate
+		}
+		if a.Course != b.Course {
+			return a.Course < b.Course
+		}
+		if a.Instructor != b.Instructor {
+			return a.Instructor < b.Instructor
+		}
+		return a.filename("") < b.filename("")
+	})
+
+	var index []ClassReport
+	for _, d := range deliveries {
+		members := byDelivery[d]
+		r := NewGroupedReport(members, names, by)
+		r.Group = d.group()
+		name := d.filename(extensions[format])
+		if err := writeReportFile(filepath.Join(dir, name), render, r); err != nil {
+			return nil, err
+		}
+		index = append(index, ClassReport{Delivery: d, File: name, Responses: len(members)})
+	}
+	if err := writeIndex(filepath.Join(dir, indexFile), index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// writeReportFile renders r to the named file.
+func writeReportFile(filename string, render Renderer, r Report) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := render(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeIndex writes index as CSV to the named file, with a row per class
+// report giving its file, delivery and number of responses.
+func writeIndex(filename string, index []ClassReport) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	cw.Write([]string{"file", "course", "course_ver", "instructor", "start_date", "modality", "responses"})
+	for _, cr := range index {
+		cw.Write([]string{cr.File, cr.Course, cr.CourseVer, cr.Instructor, cr.StartDate, cr.Modality, strconv.Itoa(cr.Responses)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWritePerClass(t *testing.T) {
+	survey := func(course, instructor, start, modality string) *Survey {
+		s := &Survey{Course: course, CourseVer: "7.2", Instructor: instructor, Modality: modality, Q207: Answer{4, true}}
+		s.StartDate.UnmarshalText([]byte(start))
+		return s
+	}
+	surveys := []*Survey{
+		survey("RH134", "Ann Lee", "2016-09-12", "ILT"),
+		survey("RH124", "Ann Lee", "2016-09-05", "ILT"),
+		survey("RH124", "Ann Lee", "09/05/2016", "ILT"), // Same delivery, another date format.
+		survey("RH124", "Ann-Lee", "2016-09-05", "ILT"), // Same readable name.
+		survey("RH124", "Ann Lee", "2016-09-05", "VT"),
+		survey("RH124", "", "", ""),
+	}
+
+	dir := filepath.Join(t.TempDir(), "reports")
+	index, err := WritePerClass(dir, "text", surveys, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type entry struct {
+		course, instructor, start, modality string
+		responses                           int
+	}
+	want := []entry{
+		{"RH124", "", "", "", 1},
+		{"RH124", "Ann Lee", "2016-09-05", "ILT", 2},
+		{"RH124", "Ann Lee", "2016-09-05", "VT", 1},
+		{"RH124", "Ann-Lee", "2016-09-05", "ILT", 1},
+		{"RH134", "Ann Lee", "2016-09-12", "ILT", 1},
+	}
+	var got []entry
+	files := make(map[string]bool)
+	name := regexp.MustCompile(`^[A-Za-z0-9-]+(_[A-Za-z0-9-]+){4}_[0-9a-f]{8}\.txt$`)
+	for _, cr := range index {
+		got = append(got, entry{cr.Course, cr.Instructor, cr.StartDate, cr.Modality, cr.Responses})
+		if !name.MatchString(cr.File) {
+			t.Errorf("report file %q is not named as expected", cr.File)
+		}
+		if files[cr.File] {
+			t.Errorf("report file %q is written twice", cr.File)
+		}
+		files[cr.File] = true
+		b, err := os.ReadFile(filepath.Join(dir, cr.File))
+		if err != nil {
+			t.Error(err)
+		} else if !strings.Contains(string(b), "instructor="+cr.Instructor) {
+			t.Errorf("%s: not a report on its class:\n%s", cr.File, b)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("index = %v, want %v", got, want)
+	}
+	if !strings.HasPrefix(index[0].File, "unknown_RH124_7-2_unknown_unknown_") {
+		t.Errorf("report with no start date, instructor or modality named %q", index[0].File)
+	}
+
+	// The index lists the same reports, and they are all that was written.
+	f, err := os.Open(filepath.Join(dir, indexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(index)+1 || strings.Join(rows[0], ",") != "file,course,course_ver,instructor,start_date,modality,responses" {
+		t.Fatalf("index.csv = %q", rows)
+	}
+	for i, cr := range index {
+		if rows[i+1][0] != cr.File || rows[i+1][3] != cr.Instructor || rows[i+1][6] != strconv.Itoa(cr.Responses) {
+			t.Errorf("index.csv row %d = %q, want %s", i+1, rows[i+1], cr.File)
+		}
+	}
+	if written, _ := filepath.Glob(filepath.Join(dir, "*")); len(written) != len(files)+1 {
+		t.Errorf("files written: %q", written)
+	}
+
+	// Reporting again gives the same file names.
+	again, err := WritePerClass(dir, "text", surveys, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range again {
+		if again[i].File != index[i].File {
+			t.Errorf("report %d written to %q, then %q", i, index[i].File, again[i].File)
+		}
+	}
+
+	if _, err := WritePerClass(dir, "pdf", surveys, false, nil); err == nil {
+		t.Errorf("WritePerClass succeeded with an unknown format")
+	}
+}
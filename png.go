@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Layout of PNG charts, in pixels.
+const (
+	pngWidth   = 820
+	pngMargin  = 20
+	pngLabelW  = 120                      // width of the labels left of bars
+	pngPlotX   = pngMargin + pngLabelW    // left edge of bars and plots
+	pngPlotW   = 500                      // width of bars and plots
+	pngRowH    = 20                       // height of a row of bars
+	pngBarH    = 14                       // height of a bar
+	pngHeaderH = 24                       // height of a section header
+	pngGap     = 16                       // space between sections
+	pngLineH   = 200                      // height of a line chart's plot area
+	pngValueX  = pngPlotX + pngPlotW + 10 // left edge of values right of bars
+	pngTitleH  = glyphHeight*2 + 12       // height of a report or trend title
+	pngLegendH = pngRowH                  // height of a legend
+	pngAxisH   = glyphHeight + 8          // height of an axis with labels
+	pngTrendH  = pngTitleH + 2*(pngHeaderH+pngLineH+pngAxisH) + pngLegendH + 2*pngGap
+)
+
+// RenderPNG writes r as a PNG image of bar charts: category averages with
+// their confidence intervals, the distribution of answers to each question,
+// and the NPS breakdown. Each of r's Groups is charted below it.
+func RenderPNG(w io.Writer, r Report) error {
+	reports := append([]Report{r}, r.Groups...)
+	height := pngMargin
+	for _, r := range reports {
+		height += reportChartHeight(r) + pngGap
+	}
+
+	c := newCanvas(pngWidth, height)
+	y := pngMargin
+	for _, r := range reports {
+		y = drawReport(c, y, r) + pngGap
+	}
+	return png.Encode(w, c)
+}
+
+// reportQuestions returns all the question stats of r, in survey order.
+func reportQuestions(r Report) []QuestionStats {
+	var qs []QuestionStats
+	qs = append(qs, r.CurriculumQuestions...)
+	qs = append(qs, r.InstructorQuestions...)
+	qs = append(qs, r.EnvironmentQuestions...)
+	qs = append(qs, r.FacilityQuestions...)
+	qs = append(qs, r.OverallQuestions...)
+	return qs
+}
+
+// reportChartHeight returns the height of the charts drawReport draws for r.
+func reportChartHeight(r Report) int {
+	return pngTitleH +
+		pngHeaderH + len(r.shownCategories())*pngRowH + pngAxisH + pngGap +
+		pngHeaderH + len(reportQuestions(r))*pngRowH + pngLegendH + pngGap +
+		pngHeaderH + pngRowH + pngLegendH +
+		readinessChartHeight(r)
+}
+
+// readinessChartHeight returns the height of the Readiness & Impact chart
+// drawReport draws for r, which is 0 if r has no Yes/No questions.
+func readinessChartHeight(r Report) int {
+	if len(r.Readiness) == 0 {
+		return 0
+	}
+	return pngGap + pngHeaderH + len(r.Readiness)*pngRowH + pngLegendH
+}
+
+// drawReport draws the charts for r starting at y, and returns the y
+// coordinate below them.
+func drawReport(c canvas, y int, r Report) int {
+	c.text(pngMargin, y, fmt.Sprintf("%s - %d responses", r.Title(), r.Responses), 2, colorText)
+	y += pngTitleH
+
+	// Category averages, with whiskers for their confidence intervals.
+	max := catalog.scoreScale()
+	y = drawHeader(c, y, fmt.Sprintf("Category averages (1-%d) with 95%% CI", max))
+	for _, cat := range r.shownCategories() {
+		drawLabel(c, y, cat.Label)
+		if cat.Count > 0 {
+			barY := y + (pngRowH-pngBarH)/2
+			c.fill(pngPlotX, barY, pngPlotX+scaleX(cat.Avg, 0, float64(max)), barY+pngBarH, colorBar)
+			if cat.Count > 1 {
+				x0 := pngPlotX + scaleX(cat.Spread.CILow, 0, float64(max))
+				x1 := pngPlotX + scaleX(cat.Spread.CIHigh, 0, float64(max))
+				mid := y + pngRowH/2
+				c.line(x0, mid, x1, mid, colorWhisker)
+				c.line(x0, mid-4, x0, mid+4, colorWhisker)
+				c.line(x1, mid-4, x1, mid+4, colorWhisker)
+			}
+		}
+		drawValue(c, y, fmt.Sprintf("%s (n=%d)", formatAvg(cat.Avg, cat.Count), cat.Count))
+		y += pngRowH
+	}
+	c.line(pngPlotX, y, pngPlotX+pngPlotW, y, colorAxis)
+	for v := 0; v <= max; v++ {
+		x := pngPlotX + scaleX(float64(v), 0, float64(max))
+		c.line(x, y, x, y+3, colorAxis)
+		c.text(x-glyphWidth/2, y+5, fmt.Sprint(v), 1, colorText)
+	}
+	y += pngAxisH + pngGap
+
+	// Stacked distribution of answers to each question.
+	y = drawHeader(c, y, "Answer distribution")
+	for _, q := range reportQuestions(r) {
+		drawLabel(c, y, q.ID)
+		barY := y + (pngRowH-pngBarH)/2
+		if q.Count == 0 {
+			c.text(pngPlotX, barY+(pngBarH-glyphHeight)/2, "no answers", 1, colorAxis)
+		} else {
+			x, sum := pngPlotX, 0
+			for i, n := range q.Histogram {
+				sum += n
+				next := pngPlotX + pngPlotW*sum/q.Count
+				c.fill(x, barY, next, barY+pngBarH, scaleColor(i+1, len(q.Histogram)))
+				x = next
+			}
+		}
+		drawValue(c, y, fmt.Sprintf("n=%d", q.Count))
+		y += pngRowH
+	}
+	x := pngPlotX
+	scale, note := answerLegend(reportQuestions(r))
+	for v := 1; v <= scale; v++ {
+		x = drawLegendItem(c, x, y+4, fmt.Sprint(v), scaleColor(v, scale))
+	}
+	c.text(x, y+4+(10-glyphHeight)/2, note, 1, colorText)
+	y += pngLegendH + pngGap
+
+	// NPS breakdown.
+	y = drawHeader(c, y, "Net Promoter Score")
+	drawLabel(c, y, "NPS")
+	barY := y + (pngRowH-pngBarH)/2
+	if total := r.Promoters + r.Passives + r.Detractors; total > 0 {
+		x := pngPlotX
+		sum := 0
+		for _, part := range []struct {
+			n   int
+			col color.Color
+		}{{r.Detractors, colorDetractor}, {r.Passives, colorPassive}, {r.Promoters, colorPromoter}} {
+			sum += part.n
+			next := pngPlotX + pngPlotW*sum/total
+			c.fill(x, barY, next, barY+pngBarH, part.col)
+			x = next
+		}
+	} else {
+		c.text(pngPlotX, barY+(pngBarH-glyphHeight)/2, "no answers", 1, colorAxis)
+	}
+	drawValue(c, y, r.formatNPS())
+	y += pngRowH
+	x = pngPlotX
+	x = drawLegendItem(c, x, y+4, fmt.Sprintf("detractors %d", r.Detractors), colorDetractor)
+	x = drawLegendItem(c, x, y+4, fmt.Sprintf("passives %d", r.Passives), colorPassive)
+	drawLegendItem(c, x, y+4, fmt.Sprintf("promoters %d", r.Promoters), colorPromoter)
+	y += pngLegendH
+	if len(r.Readiness) == 0 {
+		return y
+	}
+
+	// Share of Yes, No and blank answers to each Yes/No question.
+	y = drawHeader(c, y+pngGap, "Readiness & Impact")
+	for _, st := range r.Readiness {
+		drawLabel(c, y, st.ID)
+		barY := y + (pngRowH-pngBarH)/2
+		if st.Total() == 0 {
+			c.text(pngPlotX, barY+(pngBarH-glyphHeight)/2, "no answers", 1, colorAxis)
+		} else {
+			x, sum := pngPlotX, 0
+			for _, p := range yesNoParts(st) {
+				sum += p.n
+				next := pngPlotX + pngPlotW*sum/st.Total()
+				c.fill(x, barY, next, barY+pngBarH, p.col)
+				x = next
+			}
+		}
+		drawValue(c, y, fmt.Sprintf("%.0f%% yes", st.Percent(st.Yes)))
+		y += pngRowH
+	}
+	x = pngPlotX
+	for _, p := range yesNoParts(YesNoStats{}) {
+		x = drawLegendItem(c, x, y+4, p.label, p.col)
+	}
+	return y + pngLegendH
+}
+
+// RenderTrendPNG writes trends as a PNG image with two line charts for each:
+// the category averages by period, and the NPS by period.
+func RenderTrendPNG(w io.Writer, trends []Trend) error {
+	c := newCanvas(pngWidth, pngMargin+len(trends)*(pngTrendH+pngGap))
+	y := pngMargin
+	for _, t := range trends {
+		y = drawTrend(c, y, t) + pngGap
+	}
+	return png.Encode(w, c)
+}
+
+// drawTrend draws the line charts for t starting at y, and returns the y
+// coordinate below them.
+func drawTrend(c canvas, y int, t Trend) int {
+	c.text(pngMargin, y, t.Title(), 2, colorText)
+	y += pngTitleH
+
+	var labels []string
+	for _, tp := range t.Periods {
+		labels = append(labels, tp.Period)
+	}
+	series := []struct {
+		label string
+		score func(Scores) *float64
+	}{
+		{"Curriculum", func(s Scores) *float64 { return s.Curriculum }},
+		{"Instructor", func(s Scores) *float64 { return s.Instructor }},
+		{"Environment", func(s Scores) *float64 { return s.Environment }},
+		{"Facility", func(s Scores) *float64 { return s.Facility }},
+		{"Overall", func(s Scores) *float64 { return s.Overall }},
+	}
+
+	y = drawHeader(c, y, "Category averages by period")
+	var lines [][]*float64
+	for _, s := range series {
+		var values []*float64
+		for _, tp := range t.Periods {
+			values = append(values, s.score(tp.Scores))
+		}
+		lines = append(lines, values)
+	}
+	y = drawLineChart(c, y, labels, lines, 1, float64(catalog.scoreScale()), 1) + pngGap
+	x := pngPlotX
+	for i, s := range series {
+		x = drawLegendItem(c, x, y-pngGap+2, s.label, seriesColors[i])
+	}
+	y += pngLegendH
+
+	y = drawHeader(c, y, "NPS by period")
+	var nps []*float64
+	for _, tp := range t.Periods {
+		nps = append(nps, tp.Scores.NPS)
+	}
+	return drawLineChart(c, y, labels, [][]*float64{nps}, -100, 100, 50) + pngGap
+}
+
+// drawLineChart draws a line chart starting at y, with a line for each of
+// lines and a point on the x axis for each label. The y axis runs from min to
+// max with a grid line every step. Missing values leave gaps in their line.
+// It returns the y coordinate below the chart's axis labels.
+func drawLineChart(c canvas, y int, labels []string, lines [][]*float64, min, max, step float64) int {
+	top, bottom := y, y+pngLineH
+	toY := func(v float64) int {
+		v = math.Max(min, math.Min(max, v))
+		return bottom - int((v-min)/(max-min)*float64(pngLineH))
+	}
+	toX := func(i int) int {
+		if len(labels) < 2 {
+			return pngPlotX + pngPlotW/2
+		}
+		return pngPlotX + i*pngPlotW/(len(labels)-1)
+	}
+
+	for v := min; v <= max; v += step {
+		gy := toY(v)
+		c.line(pngPlotX, gy, pngPlotX+pngPlotW, gy, colorGrid)
+		label := fmt.Sprint(v)
+		c.text(pngPlotX-textWidth(label, 1)-6, gy-glyphHeight/2, label, 1, colorText)
+	}
+	c.line(pngPlotX, top, pngPlotX, bottom, colorAxis)
+	c.line(pngPlotX, bottom, pngPlotX+pngPlotW, bottom, colorAxis)
+
+	for i, values := range lines {
+		col := seriesColors[i%len(seriesColors)]
+		for j, v := range values {
+			if v == nil {
+				continue
+			}
+			x, vy := toX(j), toY(*v)
+			c.fill(x-2, vy-2, x+3, vy+3, col)
+			if j > 0 && values[j-1] != nil {
+				c.thickLine(toX(j-1), toY(*values[j-1]), x, vy, col)
+			}
+		}
+	}
+
+	// Label as many periods as fit without overlapping.
+	every := 1
+	if len(labels) > 1 {
+		widest := 0
+		for _, l := range labels {
+			if w := textWidth(l, 1); w > widest {
+				widest = w
+			}
+		}
+		// Labels i periods apart are pngPlotW*i/n pixels apart.
+		n := len(labels) - 1
+		every = ((widest+8)*n + pngPlotW - 1) / pngPlotW
+		if every < 1 {
+			every = 1
+		}
+	}
+	for i, l := range labels {
+		if i%every == 0 {
+			c.text(toX(i)-textWidth(l, 1)/2, bottom+5, l, 1, colorText)
+		}
+	}
+	return bottom + pngAxisH
+}
+
+// drawHeader draws a section header at y and returns the y coordinate below
+// it.
+func drawHeader(c canvas, y int, s string) int {
+	c.text(pngMargin, y+4, s, 1, colorText)
+	c.line(pngMargin, y+glyphHeight+8, pngWidth-pngMargin, y+glyphHeight+8, colorGrid)
+	return y + pngHeaderH
+}
+
+// drawLabel draws a row label left of the bars, vertically centred in the
+// row starting at y.
+func drawLabel(c canvas, y int, s string) {
+	c.text(pngMargin, y+(pngRowH-glyphHeight)/2, s, 1, colorText)
+}
+
+// drawValue draws a value right of the bars, vertically centred in the row
+// starting at y.
+func drawValue(c canvas, y int, s string) {
+	c.text(pngValueX, y+(pngRowH-glyphHeight)/2, s, 1, colorText)
+}
+
+// drawLegendItem draws a colored square followed by a label at (x, y), and
+// returns the x coordinate for the next item.
+func drawLegendItem(c canvas, x, y int, label string, col color.Color) int {
+	c.fill(x, y, x+10, y+10, col)
+	return c.text(x+14, y+(10-glyphHeight)/2+1, label, 1, colorText) + 12
+}
+
+// scaleX returns the horizontal offset of v on a bar scale from min to max
+// that is pngPlotW wide. Values outside the scale are clamped to it.
+func scaleX(v, min, max float64) int {
+	v = math.Max(min, math.Min(max, v))
+	return int((v - min) / (max - min) * pngPlotW)
+}
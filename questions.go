@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A Question describes a rated survey question.
+type Question struct {
+	ID   string `json:"id"` // Question ID, e.g. "Q207".
+	Text string `json:"text"`
+	Max  int    `json:"scale"` // Highest answer on the question's scale. The lowest is 1.
+
+	// Key is the key the question is answered under in survey files of
+	// its version, if it is not ID. Questions asked in several versions
+	// under different numbers share an ID, so that their answers are
+	// reported together.
+	Key string `json:"key,omitempty"`
+
+	// Reverse is true if a low answer is the favourable one. Its answers
+	// are flipped (1 ↔ Max) before they are counted.
+	Reverse bool `json:"reverse,omitempty"`
+
+	// NPS is true for the recommendation question the NPS is computed from.
+	// It does not count towards its category's average.
+	NPS bool `json:"nps,omitempty"`
+
+	// Modalities lists the class modalities a Yes/No question is asked
+	// in, as for CatalogCategory. Rated questions take theirs from their
+	// category.
+	Modalities []string `json:"modalities,omitempty"`
+}
+
+// key returns the key q is answered under in survey files.
+func (q Question) key() string {
+	if q.Key != "" {
+		return q.Key
+	}
+	return q.ID
+}
+
+// score returns answer a to q with reverse scoring applied.
+func (q Question) score(a Answer) Answer {
+	if q.Reverse && a.Valid {
+		a.Value = q.Max + 1 - a.Value
+	}
+	return a
+}
+
+// questionID returns the canonical form of a question ID or cookie-jar key,
+// such as "Q207" for "q2-07".
+func questionID(key string) string {
+	return strings.ToUpper(normalizeKey(key))
+}
+
+// value returns s's raw answer under the given key: the answer held in
+// Extra if there is one, or else the Survey field of that name.
+func (s *Survey) value(key string) string {
+	if v, ok := s.Extra[key]; ok {
+		return v
+	}
+	if f := reflect.ValueOf(s).Elem().FieldByName(key); f.IsValid() {
+		switch v := f.Interface().(type) {
+		case encoding.TextMarshaler:
+			b, _ := v.MarshalText()
+			return string(b)
+		case string:
+			return v
+		}
+	}
+	return ""
+}
+
+// setValue sets s's raw answer under the given key: the answer held in Extra
+// if there is one, or else the string Survey field of that name.
+func (s *Survey) setValue(key, value string) {
+	if _, ok := s.Extra[key]; ok {
+		s.Extra[key] = value
+		return
+	}
+	if f := reflect.ValueOf(s).Elem().FieldByName(key); f.IsValid() && f.Kind() == reflect.String {
+		f.SetString(value)
+	}
+}
+
+// answer returns s's answer to q, a question of s's survey version, with
+// reverse scoring applied. Answers that are not numbers count as
+// unanswered.
+func (s *Survey) answer(q Question) Answer {
+	var a Answer
+	if err := a.UnmarshalText([]byte(s.value(q.key()))); err != nil {
+		a = Answer{}
+	}
+	return q.score(a)
+}
+
+// scaled returns s's answer to q, a question of s's survey version, with
+// reverse scoring applied and rescaled to the scale of q's canonical form in
+// the catalog. ok is false if s did not answer q, or gave an answer outside
+// its scale.
+func (s *Survey) scaled(q Question) (x float64, ok bool) {
+	a := s.answer(q)
+	if !a.Valid || a.Value < 1 || a.Value > q.Max {
+		return 0, false
+	}
+	return rescale(float64(a.Value), q.Max, catalog.canonical(q).Max), true
+}
+
+// rescale maps x from a scale of 1 to from onto a scale of 1 to to.
+func rescale(x float64, from, to int) float64 {
+	if from == to {
+		return x
+	}
+	return 1 + (x-1)*float64(to-1)/float64(from-1)
+}
+
+// questionValue returns s's answer to the question with the given ID as
+// reports count it: for a rated question, the answer given by scaled, or the
+// raw answer if it has none on the question's scale; for a Yes/No question,
+// the raw answer. Either is read under the key s's survey version asks the
+// question under, and an answer under a key the version gives to another
+// question is not an answer to id.
+func (s *Survey) questionValue(id string) string {
+	v := catalog.version(s.SurveyVer)
+	if q, ok := v.question(id); ok {
+		if x, ok := s.scaled(q); ok {
+			return strconv.FormatFloat(x, 'f', -1, 64)
+		}
+		return s.value(q.key())
+	}
+	if q, ok := v.yesNoQuestion(id); ok {
+		return s.value(q.key())
+	}
+	if _, ok := v.questionByKey(id); ok {
+		return ""
+	}
+	return s.value(id)
+}
+
+// meanScore returns the mean of s's answers to qs, questions of s's survey
+// version, as given by scaled. ok is false if s answered none of them on
+// their scale.
+func (s *Survey) meanScore(qs []Question) (mean float64, ok bool) {
+	var x sample
+	for _, q := range qs {
+		if v, ok := s.scaled(q); ok {
+			x.add(v)
+		}
+	}
+	return x.mean(), len(x) > 0
+}
+
+// QuestionStats summarises the answers to one rated question. Blank, N/A and
+// out-of-range answers are not counted, and reverse-scored answers are
+// counted flipped. Answers from survey versions where the question has a
+// different scale are rescaled, and counted in the histogram at the nearest
+// whole answer.
+type QuestionStats struct {
+	ID    string
+	Text  string
+	Mean  float64 // 0 if Count is 0.
+	Count int
+
+	// Histogram[i] is the number of learners who answered i+1.
+	Histogram []int
+
+	Spread
+}
+
+// newQuestionStats returns the QuestionStats for each of qs, which must be in
+// their canonical form, over surveys. Only surveys whose version asks a
+// question count towards its stats.
+func newQuestionStats(qs []Question, surveys []*Survey) []QuestionStats {
+	stats := make([]QuestionStats, len(qs))
+	for i, q := range qs {
+		st := QuestionStats{ID: q.ID, Text: q.Text, Histogram: make([]int, q.Max)}
+		x := questionSample(q, surveys)
+		for _, v := range x {
+			st.Histogram[int(math.Floor(v+0.5))-1]++
+		}
+		st.Mean = x.mean()
+		st.Count = len(x)
+		st.Spread = newSpread(x).clamp(1, float64(q.Max))
+		stats[i] = st
+	}
+	return stats
+}
+
+// questionSample returns the answers to q, which must be in its canonical
+// form, over surveys, as counted by newQuestionStats.
+func questionSample(q Question, surveys []*Survey) sample {
+	var x sample
+	for _, s := range surveys {
+		vq, ok := catalog.version(s.SurveyVer).question(q.ID)
+		if !ok {
+			continue
+		}
+		if v, ok := s.scaled(vq); ok {
+			x.add(v)
+		}
+	}
+	return x
+}
+
+// categorySample returns the average answer of each learner to the named
+// category, over the surveys it applies to. Only questions the learner
+// actually answered count towards their average, and learners who answered
+// none are left out.
+func categorySample(category string, surveys []*Survey) sample {
+	var x sample
+	for _, s := range surveys {
+		c := catalog.version(s.SurveyVer).category(category)
+		if !c.applies(s) {
+			continue
+		}
+		if avg, ok := s.meanScore(c.scored()); ok {
+			x.add(avg)
+		}
+	}
+	return x
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestCategorySample(t *testing.T) {
+	tests := []struct {
+		name    string
+		surveys []*Survey
+		want    sample
+	}{
+		{
+			name: "unanswered questions are not counted",
+			surveys: []*Survey{
+				{Q207: Answer{4, true}, Q208: Answer{2, true}},
+				{Q209: Answer{5, true}},
+				{},
+			},
+			want: sample{3, 5},
+		},
+		{
+			name: "out-of-range answers are not counted",
+			surveys: []*Survey{
+				{Q207: Answer{4, true}, Q208: Answer{9, true}, Q209: Answer{0, true}},
+				{Q207: Answer{-1, true}},
+			},
+			want: sample{4},
+		},
+	}
+	for _, tt := range tests {
+		got := categorySample("curriculum", tt.surveys)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: categorySample = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: categorySample = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
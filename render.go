@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Renderer writes a Report to w in a particular output format.
+type Renderer func(w io.Writer, r Report) error
+
+// renderers maps output format names (as passed to -f) to their Renderer.
+var renderers = map[string]Renderer{
+	"csv":  RenderCSV,
+	"html": RenderHTML,
+	"json": RenderJSON,
+	"png":  RenderPNG,
+	"text": RenderText,
+}
+
+// Formats returns the sorted names of all supported output formats.
+func Formats() []string {
+	return sortedKeys(renderers)
+}
+
+// LookupRenderer returns the Renderer registered for format, or an error
+// listing the supported formats if there is none.
+func LookupRenderer(format string) (Renderer, error) {
+	render, ok := renderers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(Formats(), ", "))
+	}
+	return render, nil
+}
+
+// RenderText writes r as aligned plain text.
+func RenderText(w io.Writer, r Report) error {
+	_, err := io.WriteString(w, r.String())
+	return err
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/gonum/stat"
+	"github.com/gonum/stat/distuv"
+)
+
+// bootstrapSamples is the number of resamples used for bootstrapped
+// confidence intervals.
+const bootstrapSamples = 2000
+
+// sample accumulates values for computing summary statistics.
+type sample []float64
+
+// add includes x in the sample.
+func (s *sample) add(x float64) {
+	*s = append(*s, x)
+}
+
+// mean returns the mean of the sample, or 0 if it is empty.
+func (s sample) mean() float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	return stat.Mean(s, nil)
+}
+
+// Spread describes how the values in a sample are spread around their mean.
+type Spread struct {
+	StdDev float64
+	Median float64
+
+	// CILow and CIHigh bound the 95% confidence interval for the mean,
+	// based on Student's t-distribution. Both equal the mean if there are
+	// fewer than two values. For scores, the interval is clamped to the
+	// answer scale.
+	CILow  float64
+	CIHigh float64
+}
+
+// newSpread returns the Spread of x. It is the zero Spread if x is empty.
+func newSpread(x sample) Spread {
+	if len(x) == 0 {
+		return Spread{}
+	}
+
+	sorted := append(sample(nil), x...)
+	sort.Float64s(sorted)
+	mean := stat.Mean(sorted, nil)
+	sp := Spread{
+		Median: median(sorted),
+		CILow:  mean,
+		CIHigh: mean,
+	}
+	if len(x) < 2 {
+		return sp
+	}
+
+	sp.StdDev = stat.StdDev(sorted, nil)
+	n := float64(len(x))
+	t := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: n - 1}.Quantile(0.975)
+	margin := t * stat.StdErr(sp.StdDev, n)
+	sp.CILow, sp.CIHigh = mean-margin, mean+margin
+	return sp
+}
+
+// clamp returns sp with its confidence interval limited to lo to hi, the
+// range of possible values. With few values, the interval given by the
+// t-distribution can reach well past the ends of an answer scale.
+func (sp Spread) clamp(lo, hi float64) Spread {
+	sp.CILow = math.Max(lo, math.Min(hi, sp.CILow))
+	sp.CIHigh = math.Max(lo, math.Min(hi, sp.CIHigh))
+	return sp
+}
+
+// median returns the median of sorted, a sorted, non-empty sample: its
+// middle value, or the mean of its two middle values if it has an even
+// number of them.
+func median(sorted sample) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// bootstrapNPS returns a 95% percentile bootstrap confidence interval for the
+// NPS of the given respondents. The resampling is seeded, so the result is
+// the same on every run. ok is false if there are no respondents.
+func bootstrapNPS(promoters, passives, detractors int) (low, high float64, ok bool) {
+	n := promoters + passives + detractors
+	if n == 0 {
+		return 0, 0, false
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	scores := make([]float64, bootstrapSamples)
+	for i := range scores {
+		var p, d int
+		for j := 0; j < n; j++ {
+			switch k := rnd.Intn(n); {
+			case k < promoters:
+				p++
+			case k >= promoters+passives:
+				d++
+			}
+		}
+		scores[i], _ = NPS(p, n-p-d, d)
+	}
+	sort.Float64s(scores)
+	low = stat.Quantile(0.025, stat.Empirical, scores, nil)
+	high = stat.Quantile(0.975, stat.Empirical, scores, nil)
+	return low, high, true
+}
+
+// welchTTest returns the two-sided p-value of Welch's t-test for a
+// difference between the means of a and b. ok is false if either sample has
+// fewer than two values or both have no variance.
+func welchTTest(a, b sample) (p float64, ok bool) {
+	if len(a) < 2 || len(b) < 2 {
+		return 0, false
+	}
+	na, nb := float64(len(a)), float64(len(b))
+	ma, va := stat.MeanVariance(a, nil)
+	mb, vb := stat.MeanVariance(b, nil)
+	sa, sb := va/na, vb/nb
+	if sa+sb == 0 {
+		return 0, false
+	}
+	t := (ma - mb) / math.Sqrt(sa+sb)
+	df := (sa + sb) * (sa + sb) / (sa*sa/(na-1) + sb*sb/(nb-1))
+	return 2 * distuv.StudentsT{Mu: 0, Sigma: 1, Nu: df}.CDF(-math.Abs(t)), true
+}
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U test for
+// a difference between the distributions of a and b, using the normal
+// approximation with tie and continuity corrections. ok is false if either
+// sample is empty or every value is the same.
+func mannWhitneyU(a, b sample) (p float64, ok bool) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, false
+	}
+	type value struct {
+		x     float64
+		fromA bool
+	}
+	var all []value
+	for _, x := range a {
+		all = append(all, value{x, true})
+	}
+	for _, x := range b {
+		all = append(all, value{x, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].x < all[j].x })
+
+	// Tied values share the mean of their ranks.
+	var rankA, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].x == all[i].x {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].fromA {
+				rankA += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	na, nb := float64(len(a)), float64(len(b))
+	n := na + nb
+	u := rankA - na*(na+1)/2
+	variance := na * nb / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 0, false
+	}
+	z := (math.Abs(u-na*nb/2) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		z = 0
+	}
+	return 2 * distuv.UnitNormal.CDF(-z), true
+}
+
+// npsZTest returns the two-sided p-value of a z-test for a difference
+// between the NPS of two groups of respondents. It extends the two-proportion
+// z-test to the NPS, the difference of the promoter and detractor
+// proportions, whose variance is (p + d - (p-d)²) / n. ok is false if either
+// group has no respondents or both have no variance.
+func npsZTest(promotersA, passivesA, detractorsA, promotersB, passivesB, detractorsB int) (p float64, ok bool) {
+	variance := func(promoters, passives, detractors int) (nps, v float64, ok bool) {
+		n := float64(promoters + passives + detractors)
+		if n == 0 {
+			return 0, 0, false
+		}
+		pp, pd := float64(promoters)/n, float64(detractors)/n
+		return pp - pd, (pp + pd - (pp-pd)*(pp-pd)) / n, true
+	}
+	npsA, va, okA := variance(promotersA, passivesA, detractorsA)
+	npsB, vb, okB := variance(promotersB, passivesB, detractorsB)
+	if !okA || !okB || va+vb == 0 {
+		return 0, false
+	}
+	z := (npsA - npsB) / math.Sqrt(va+vb)
+	return 2 * distuv.UnitNormal.CDF(-math.Abs(z)), true
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// The expected p-values were computed separately from the textbook
+// definitions of the tests, integrating the t-distribution numerically.
+
+func TestWelchTTest(t *testing.T) {
+	tests := []struct {
+		a, b sample
+		p    float64
+		ok   bool
+	}{
+		{sample{1, 2, 3, 4, 5}, sample{3, 4, 5, 6, 7, 8}, 0.03980, true},
+		{sample{4, 5, 4, 5, 5, 3}, sample{2, 3, 3, 4, 2}, 0.01439, true},
+		{sample{1, 2, 3}, sample{1, 2, 3}, 1, true},
+		{sample{4}, sample{1, 2, 3}, 0, false},
+		{sample{3, 3}, sample{3, 3, 3}, 0, false},
+	}
+	for _, tt := range tests {
+		p, ok := welchTTest(tt.a, tt.b)
+		if ok != tt.ok || math.Abs(p-tt.p) > 1e-4 {
+			t.Errorf("welchTTest(%v, %v) = %.5f, %v; want %.5f, %v", tt.a, tt.b, p, ok, tt.p, tt.ok)
+		}
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	tests := []struct {
+		a, b sample
+		p    float64
+		ok   bool
+	}{
+		{sample{1, 2, 3, 4, 5}, sample{3, 4, 5, 6, 7, 8}, 0.06602, true},
+		{sample{1, 1, 2, 2, 3}, sample{2, 3, 3, 4, 4, 5}, 0.03163, true},
+		{sample{}, sample{1, 2}, 0, false},
+		{sample{4, 4}, sample{4, 4, 4}, 0, false},
+	}
+	for _, tt := range tests {
+		p, ok := mannWhitneyU(tt.a, tt.b)
+		if ok != tt.ok || math.Abs(p-tt.p) > 1e-4 {
+			t.Errorf("mannWhitneyU(%v, %v) = %.5f, %v; want %.5f, %v", tt.a, tt.b, p, ok, tt.p, tt.ok)
+		}
+	}
+}
+
+func TestNPSZTest(t *testing.T) {
+	tests := []struct {
+		a, b [3]int // promoters, passives, detractors
+		p    float64
+		ok   bool
+	}{
+		{[3]int{50, 30, 20}, [3]int{30, 30, 40}, 0.00045, true},
+		{[3]int{10, 5, 5}, [3]int{10, 5, 5}, 1, true},
+		{[3]int{0, 0, 0}, [3]int{10, 5, 5}, 0, false},
+		{[3]int{0, 5, 0}, [3]int{0, 7, 0}, 0, false},
+	}
+	for _, tt := range tests {
+		p, ok := npsZTest(tt.a[0], tt.a[1], tt.a[2], tt.b[0], tt.b[1], tt.b[2])
+		if ok != tt.ok || math.Abs(p-tt.p) > 1e-5 {
+			t.Errorf("npsZTest(%v, %v) = %.5f, %v; want %.5f, %v", tt.a, tt.b, p, ok, tt.p, tt.ok)
+		}
+	}
+}
+
+func TestNewSpreadMedian(t *testing.T) {
+	tests := []struct {
+		x      sample
+		median float64
+	}{
+		{sample{4}, 4},
+		{sample{7, 10}, 8.5},
+		{sample{3, 2}, 2.5},
+		{sample{5, 1, 3}, 3},
+		{sample{1, 2, 4, 5}, 3},
+	}
+	for _, tt := range tests {
+		if got := newSpread(tt.x).Median; got != tt.median {
+			t.Errorf("newSpread(%v).Median = %v, want %v", tt.x, got, tt.median)
+		}
+	}
+}
+
+func TestSpreadClamp(t *testing.T) {
+	tests := []struct {
+		x           sample
+		low, high   float64
+		clampedLow  float64
+		clampedHigh float64
+	}{
+		{sample{4, 5}, -1.85, 10.85, 1, 5},
+		{sample{3, 4, 5}, 1.52, 6.48, 1.52, 5},
+		{sample{4, 4, 5, 5, 4, 5, 4, 5}, 4.05, 4.95, 4.05, 4.95},
+		{sample{3}, 3, 3, 3, 3},
+	}
+	for _, tt := range tests {
+		sp := newSpread(tt.x)
+		if math.Abs(sp.CILow-tt.low) > 0.01 || math.Abs(sp.CIHigh-tt.high) > 0.01 {
+			t.Errorf("newSpread(%v) CI = [%.2f, %.2f], want [%.2f, %.2f]", tt.x, sp.CILow, sp.CIHigh, tt.low, tt.high)
+		}
+		sp = sp.clamp(1, 5)
+		if math.Abs(sp.CILow-tt.clampedLow) > 0.01 || math.Abs(sp.CIHigh-tt.clampedHigh) > 0.01 {
+			t.Errorf("newSpread(%v).clamp(1, 5) CI = [%.2f, %.2f], want [%.2f, %.2f]", tt.x, sp.CILow, sp.CIHigh, tt.clampedLow, tt.clampedHigh)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+// Scores holds the headline numbers of a Report. A score is nil if it is not
+// available, for instance because nobody answered the category.
+type Scores struct {
+	Responses   int
+	Curriculum  *float64
+	Instructor  *float64
+	Environment *float64
+	Facility    *float64
+	Overall     *float64
+	NPS         *float64
+}
+
+// scoresOf returns the headline numbers of r.
+func scoresOf(r Report) Scores {
+	score := func(v float64, ok bool) *float64 {
+		if !ok {
+			return nil
+		}
+		return &v
+	}
+	return Scores{
+		Responses:   r.Responses,
+		Curriculum:  score(r.CurriculumAvg, r.CurriculumCount > 0),
+		Instructor:  score(r.InstructorAvg, r.InstructorCount > 0),
+		Environment: score(r.EnvironmentAvg, r.EnvironmentCount > 0),
+		Facility:    score(r.FacilityAvg, r.FacilityCount > 0),
+		Overall:     score(r.OverallAvg, r.OverallCount > 0),
+		NPS:         score(r.NPS, r.NPSAvailable),
+	}
+}
+
+// sub returns the change in each score from prev to s. A change is nil if
+// either score is nil.
+func (s Scores) sub(prev Scores) Scores {
+	diff := func(a, b *float64) *float64 {
+		if a == nil || b == nil {
+			return nil
+		}
+		d := *a - *b
+		return &d
+	}
+	return Scores{
+		Responses:   s.Responses - prev.Responses,
+		Curriculum:  diff(s.Curriculum, prev.Curriculum),
+		Instructor:  diff(s.Instructor, prev.Instructor),
+		Environment: diff(s.Environment, prev.Environment),
+		Facility:    diff(s.Facility, prev.Facility),
+		Overall:     diff(s.Overall, prev.Overall),
+		NPS:         diff(s.NPS, prev.NPS),
+	}
+}
+
+// A TrendPeriod gives the scores over all classes starting in one period.
+type TrendPeriod struct {
+	Period  string
+	Classes int
+	Scores  Scores
+	Delta   *Scores // Change since the previous period; nil for the first.
+}
+
+// A RollingScore gives the scores over a class and the classes before it.
+type RollingScore struct {
+	Class
+	Scores Scores
+}
+
+// A Trend shows how the scores of a series of classes move over time.
+type Trend struct {
+	Group   map[string]string // As for Report.Group.
+	Periods []TrendPeriod
+
+	// Rolling holds, for each class in order of start date, the scores over
+	// it and up to Window-1 classes before it.
+	Window  int
+	Rolling []RollingScore
+}
+
+// Title returns a short description of the classes in the trend.
+func (t Trend) Title() string {
+	return Report{Group: t.Group}.Title()
+}
+
+// NewTrend returns the Trend of hs, which must be ordered by start date,
+// bucketed by period and with rolling scores over window classes.
+func NewTrend(hs []*ClassHistory, period func(string) string, window int) Trend {
+	t := Trend{Window: window}
+
+	byPeriod := make(map[string][]*Survey)
+	classes := make(map[string]int)
+	var keys []string
+	for _, h := range hs {
+		p := period(h.StartDate)
+		if _, ok := byPeriod[p]; !ok {
+			keys = append(keys, p)
+		}
+		byPeriod[p] = append(byPeriod[p], h.Surveys...)
+		classes[p]++
+	}
+	sort.Strings(keys)
+
+	for i, p := range keys {
+		tp := TrendPeriod{Period: p, Classes: classes[p], Scores: scoresOf(NewReport(byPeriod[p], false))}
+		if i > 0 {
+			d := tp.Scores.sub(t.Periods[i-1].Scores)
+			tp.Delta = &d
+		}
+		t.Periods = append(t.Periods, tp)
+	}
+
+	for i, h := range hs {
+		var surveys []*Survey
+		for j := i - window + 1; j <= i; j++ {
+			if j >= 0 {
+				surveys = append(surveys, hs[j].Surveys...)
+			}
+		}
+		t.Rolling = append(t.Rolling, RollingScore{Class: h.Class, Scores: scoresOf(NewReport(surveys, false))})
+	}
+	return t
+}
+
+// trendFields maps the names of the fields trends can be split by to a
+// function returning that field of a class.
+var trendFields = map[string]func(Class) string{
+	"course":     func(c Class) string { return c.Course },
+	"instructor": func(c Class) string { return c.Instructor },
+}
+
+// runTrend implements the trend command, which shows how scores saved in the
+// history store move over time.
+func runTrend(args []string) {
+	fs := flag.NewFlagSet("trend", flag.ExitOnError)
+	st := historyFlags(fs)
+	by := fs.String("by", "", "show a separate trend for each course or instructor")
+	period := fs.String("period", "month", "period to bucket classes by (week, month, quarter, year)")
+	window := fs.Int("rolling", 3, "number of classes in each rolling average")
+	format := fs.String("f", "text", "output format (text, json, png)")
+	catalogFile := catalogFlag(fs)
+	tz := tzFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s trend [-catalog file] [-tz zone] [-dir dir] [-by field] [-period period] [-rolling n] [-f format]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	useCatalog(*catalogFile)
+	useTimeZone(*tz)
+
+	periodFunc, ok := periods[*period]
+	if !ok {
+		log.Fatalf("[INFO] unknown period %q (supported: week, month, quarter, year)\n", *period)
+	}
+	field, ok := trendFields[*by]
+	if *by != "" && !ok {
+		log.Fatalf("[INFO] unknown trend field %q (supported: course, instructor)\n", *by)
+	}
+	if *window < 1 {
+		log.Fatalf("[INFO] -rolling must be at least 1\n")
+	}
+	if *format != "text" && *format != "json" && *format != "png" {
+		log.Fatalf("[INFO] unknown format %q (supported: json, png, text)\n", *format)
+	}
+
+	hs, err := st.Load()
+	if err != nil {
+		log.Fatalf("[INFO] Error loading history: %s\n", err)
+	}
+
+	bucket := func(startDate string) string { return periodOf(periodFunc, startDate) }
+	var trends []Trend
+	if *by == "" {
+		trends = append(trends, NewTrend(hs, bucket, *window))
+	} else {
+		series := make(map[string][]*ClassHistory)
+		var keys []string
+		for _, h := range hs {
+			k := field(h.Class)
+			if _, ok := series[k]; !ok {
+				keys = append(keys, k)
+			}
+			series[k] = append(series[k], h)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			t := NewTrend(series[k], bucket, *window)
+			t.Group = map[string]string{*by: k}
+			trends = append(trends, t)
+		}
+	}
+
+	switch *format {
+	case "png":
+		if err := RenderTrendPNG(os.Stdout, trends); err != nil {
+			log.Fatalf("[INFO] Error rendering trend: %s\n", err)
+		}
+		return
+	case "json":
+		b, err := json.MarshalIndent(trends, "", "  ")
+		if err != nil {
+			log.Fatalf("[INFO] Error rendering trend: %s\n", err)
+		}
+		fmt.Printf("%s\n", b)
+		return
+	}
+	for i, t := range trends {
+		if i > 0 {
+			fmt.Println()
+		}
+		writeTrend(os.Stdout, t)
+	}
+}
+
+// writeTrend writes t as two tables: scores by period with the change since
+// the previous period, and rolling scores by class.
+func writeTrend(w io.Writer, t Trend) {
+	fmt.Fprintf(w, "=== %s ===\n", t.Title())
+	fmt.Fprintf(w, "%-9s %7s %14s %14s %14s %14s %14s %14s %16s\n",
+		"Period", "Classes", "Responses", "Curriculum", "Instructor", "Environment", "Facility", "Overall", "NPS")
+	for _, tp := range t.Periods {
+		d := tp.Delta
+		if d == nil {
+			d = &Scores{}
+		}
+		responses := fmt.Sprint(tp.Scores.Responses)
+		if tp.Delta != nil {
+			responses += fmt.Sprintf(" (%+d)", d.Responses)
+		}
+		writeLine(w, "%-9s %7d %14s %14s %14s %14s %14s %14s %16s",
+			tp.Period, tp.Classes, responses,
+			formatTrendScore(tp.Scores.Curriculum, d.Curriculum),
+			formatTrendScore(tp.Scores.Instructor, d.Instructor),
+			formatTrendScore(tp.Scores.Environment, d.Environment),
+			formatTrendScore(tp.Scores.Facility, d.Facility),
+			formatTrendScore(tp.Scores.Overall, d.Overall),
+			formatTrendScore(tp.Scores.NPS, d.NPS))
+	}
+
+	fmt.Fprintf(w, "\nRolling %d-class averages\n", t.Window)
+	fmt.Fprintf(w, "%-10s %-30s %9s %10s %10s %11s %8s %7s %7s\n",
+		"Start", "Class", "Responses", "Curriculum", "Instructor", "Environment", "Facility", "Overall", "NPS")
+	for _, rs := range t.Rolling {
+		writeLine(w, "%-10s %-30s %9d %10s %10s %11s %8s %7s %7s",
+			rs.StartDate, rs.Course+" / "+rs.Instructor, rs.Scores.Responses,
+			formatTrendScore(rs.Scores.Curriculum, nil),
+			formatTrendScore(rs.Scores.Instructor, nil),
+			formatTrendScore(rs.Scores.Environment, nil),
+			formatTrendScore(rs.Scores.Facility, nil),
+			formatTrendScore(rs.Scores.Overall, nil),
+			formatTrendScore(rs.Scores.NPS, nil))
+	}
+}
+
+// formatTrendScore formats a score followed by its change, if known, or
+// returns "n/a" if the score is not available.
+func formatTrendScore(score, delta *float64) string {
+	if score == nil {
+		return "n/a"
+	}
+	if delta == nil {
+		return fmt.Sprintf("%.2f", *score)
+	}
+	return fmt.Sprintf("%.2f (%+.2f)", *score, *delta)
+}
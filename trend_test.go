@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewTrend(t *testing.T) {
+	class := func(start string, q207 ...int) *ClassHistory {
+		h := &ClassHistory{Class: Class{Course: "RH124", Instructor: "Ann Lee", StartDate: start}}
+		for _, v := range q207 {
+			h.Surveys = append(h.Surveys, &Survey{Q207: Answer{v, true}, Q410: Answer{10, true}})
+		}
+		return h
+	}
+	hs := []*ClassHistory{
+		class("2016-09-05", 3, 5),
+		class("2016-09-20", 4),
+		class("2016-10-03", 2),
+		class("2016-12-01"),
+		class("2017-01-10", 5, 5, 2),
+		class("TBD", 4),
+	}
+	bucket := func(startDate string) string { return periodOf(periods["month"], startDate) }
+	tr := NewTrend(hs, bucket, 2)
+
+	type period struct {
+		name       string
+		classes    int
+		responses  int
+		curriculum *float64
+		delta      *float64 // Change in the curriculum score.
+	}
+	f := func(x float64) *float64 { return &x }
+	want := []period{
+		{"2016-09", 2, 3, f(4), nil},
+		{"2016-10", 1, 1, f(2), f(-2)},
+		{"2016-12", 1, 0, nil, nil},
+		{"2017-01", 1, 3, f(4), nil},
+		{"unknown", 1, 1, f(4), f(0)},
+	}
+	if len(tr.Periods) != len(want) {
+		t.Fatalf("%d periods, want %d", len(tr.Periods), len(want))
+	}
+	for i, w := range want {
+		p := tr.Periods[i]
+		if p.Period != w.name || p.Classes != w.classes || p.Scores.Responses != w.responses {
+			t.Errorf("period %d = %s, %d class(es), %d response(s); want %s, %d, %d",
+				i, p.Period, p.Classes, p.Scores.Responses, w.name, w.classes, w.responses)
+		}
+		if !equalScore(p.Scores.Curriculum, w.curriculum) {
+			t.Errorf("%s: curriculum %s, want %s", w.name, formatTrendScore(p.Scores.Curriculum, nil), formatTrendScore(w.curriculum, nil))
+		}
+		if i == 0 {
+			if p.Delta != nil {
+				t.Errorf("%s: first period has a delta", w.name)
+			}
+			continue
+		}
+		if p.Delta == nil {
+			t.Errorf("%s: no delta", w.name)
+			continue
+		}
+		if !equalScore(p.Delta.Curriculum, w.delta) {
+			t.Errorf("%s: curriculum delta %s, want %s", w.name, formatTrendScore(p.Delta.Curriculum, nil), formatTrendScore(w.delta, nil))
+		}
+		if d := p.Delta.Responses; d != w.responses-want[i-1].responses {
+			t.Errorf("%s: responses delta %d, want %d", w.name, d, w.responses-want[i-1].responses)
+		}
+	}
+
+	// Each rolling score covers a class and the one before it.
+	rolling := []*float64{f(4), f(4), f(3), f(2), f(4), f(4)}
+	responses := []int{2, 3, 2, 1, 3, 4}
+	if len(tr.Rolling) != len(rolling) {
+		t.Fatalf("%d rolling scores, want %d", len(tr.Rolling), len(rolling))
+	}
+	for i, rs := range tr.Rolling {
+		if rs.Class != hs[i].Class || rs.Scores.Responses != responses[i] || !equalScore(rs.Scores.Curriculum, rolling[i]) {
+			t.Errorf("rolling score %d = %s, %d response(s), curriculum %s; want %s, %d, %s", i,
+				rs.StartDate, rs.Scores.Responses, formatTrendScore(rs.Scores.Curriculum, nil),
+				hs[i].StartDate, responses[i], formatTrendScore(rolling[i], nil))
+		}
+		if rs.Scores.NPS == nil && rs.Scores.Responses > 0 {
+			t.Errorf("rolling score %d: no NPS", i)
+		}
+	}
+}
+
+// equalScore reports whether a and b are both nil or nearly equal.
+func equalScore(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return math.Abs(*a-*b) < 1e-9
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// requiredKeys are the cookie-jar keys every record must have a value for.
+var requiredKeys = []string{"course", "instructor", "start_date"}
+
+// dateKeys are the cookie-jar keys whose values must be valid dates.
+var dateKeys = []string{"start_date", "surveydate"}
+
+// Checks reported in Findings.
+const (
+	CheckSyntax         = "syntax"
+	CheckInvalidAnswer  = "invalid-answer"
+	CheckOutOfRange     = "out-of-range"
+	CheckUnknownField   = "unknown-field"
+	CheckMissingField   = "missing-field"
+	CheckInvalidDate    = "invalid-date"
+	CheckDuplicate      = "duplicate"
+	CheckUnknownVersion = "unknown-version"
+)
+
+// A Finding is a problem found in a survey record by Validator.
+type Finding struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Record  int    `json:"record"`
+	Field   string `json:"field,omitempty"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	field := ""
+	if f.Field != "" {
+		field = f.Field + ": "
+	}
+	return fmt.Sprintf("%s:%d: record %d: [%s] %s%s", f.File, f.Line, f.Record, f.Check, field, f.Message)
+}
+
+// A Validator checks survey records against the Survey schema. It remembers
+// a fingerprint of every record it has seen, so that duplicates can be found
+// across inputs.
+type Validator struct {
+	seen map[[sha1.Size]byte]string // record fingerprint → location of first occurrence
+}
+
+// NewValidator returns a new Validator.
+func NewValidator() *Validator {
+	return &Validator{seen: make(map[[sha1.Size]byte]string)}
+}
+
+// Validate returns the problems found in rec.
+func (v *Validator) Validate(rec *Record) []Finding {
+	var findings []Finding
+	add := func(line int, field, check, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			File:    rec.File,
+			Line:    line,
+			Record:  rec.Number,
+			Field:   field,
+			Check:   check,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	firstLine := 0
+	if len(rec.Fields) > 0 {
+		firstLine = rec.Fields[0].Line
+	}
+
+	values := make(map[string]string)
+	for _, f := range rec.Fields {
+		values[normalizeKey(f.Key)] = f.Value
+	}
+	version := catalog.version(values[normalizeKey("survey_ver")])
+
+	t := reflect.TypeOf(Survey{})
+	for _, f := range rec.Fields {
+		key := normalizeKey(f.Key)
+		idx, isField := surveyFields[key]
+		if !isQuestionKey(key) && !isField {
+			continue
+		}
+
+		id := questionID(key)
+		if !isField && !catalog.isQuestion(id) {
+			add(f.Line, f.Key, CheckUnknownField, "no survey question matches this key")
+			continue
+		}
+		q, rated := version.questionByKey(id)
+		if !rated && (!isField || t.Field(idx).Type != reflect.TypeOf(Answer{})) {
+			continue
+		}
+		var a Answer
+		if err := a.UnmarshalText([]byte(f.Value)); err != nil {
+			add(f.Line, f.Key, CheckInvalidAnswer, "%s", err)
+			continue
+		}
+		if rated && a.Valid && (a.Value < 1 || a.Value > q.Max) {
+			add(f.Line, f.Key, CheckOutOfRange, "answer %d outside 1-%d", a.Value, q.Max)
+		}
+	}
+
+	for _, key := range requiredKeys {
+		if strings.TrimSpace(values[normalizeKey(key)]) == "" {
+			add(firstLine, key, CheckMissingField, "required field is missing or empty")
+		}
+	}
+
+	for _, f := range rec.Fields {
+		for _, key := range dateKeys {
+			if normalizeKey(f.Key) != normalizeKey(key) || strings.TrimSpace(f.Value) == "" {
+				continue
+			}
+			if _, err := parseDate(strings.TrimSpace(f.Value)); err != nil {
+				add(f.Line, f.Key, CheckInvalidDate, "%s", err)
+			}
+		}
+	}
+
+	for _, f := range rec.Fields {
+		if normalizeKey(f.Key) == normalizeKey("survey_ver") && strings.TrimSpace(f.Value) != "" && !catalog.isVersion(f.Value) {
+			add(f.Line, f.Key, CheckUnknownVersion, "survey version %q is not in the catalog, scored as version %q", strings.TrimSpace(f.Value), catalog.Default)
+		}
+	}
+
+	fp := fingerprint(rec)
+	location := fmt.Sprintf("%s:%d (record %d)", rec.File, firstLine, rec.Number)
+	if first, ok := v.seen[fp]; ok {
+		add(firstLine, "", CheckDuplicate, "duplicate of %s", first)
+	} else {
+		v.seen[fp] = location
+	}
+
+	return findings
+}
+
+// isQuestionKey reports whether the normalized key names a survey question,
+// such as "q207".
+func isQuestionKey(key string) bool {
+	if len(key) < 2 || key[0] != 'q' {
+		return false
+	}
+	for _, r := range key[1:] {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint returns a digest identifying the contents of rec, regardless
+// of field order.
+func fingerprint(rec *Record) [sha1.Size]byte {
+	var kvs []string
+	for _, f := range rec.Fields {
+		kvs = append(kvs, normalizeKey(f.Key)+"="+f.Value)
+	}
+	sort.Strings(kvs)
+	return sha1.Sum([]byte(strings.Join(kvs, "\n")))
+}
+
+// check returns the problems with rec, as returned by a RecordReader along
+// with err: the syntax error err holds, if any, followed by those found by
+// Validate.
+func (v *Validator) check(rec *Record, err error) []Finding {
+	// A record with nothing but a syntax error has no fields to check.
+	var findings []Finding
+	if len(rec.Fields) > 0 || err == nil {
+		findings = v.Validate(rec)
+	}
+	if perr, ok := err.(*ParseError); ok {
+		findings = append([]Finding{{
+			File:    perr.File,
+			Line:    perr.Line,
+			Record:  perr.Record,
+			Check:   CheckSyntax,
+			Message: perr.Err.Error(),
+		}}, findings...)
+	}
+	return findings
+}
+
+// runValidate implements the validate command, which checks survey files
+// against the Survey schema without producing a report. It exits with status
+// 1 if any problems are found.
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	format := fs.String("f", "text", "output format (text, json)")
+	catalogFile := catalogFlag(fs)
+	tz := tzFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s validate [-catalog file] [-tz zone] [-f format] [file ...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	useCatalog(*catalogFile)
+	useTimeZone(*tz)
+
+	var write func(Finding) error
+	switch *format {
+	case "text":
+		write = func(f Finding) error {
+			_, err := fmt.Println(f)
+			return err
+		}
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		write = func(f Finding) error { return enc.Encode(f) }
+	default:
+		log.Fatalf("[INFO] unknown format %q (supported: json, text)\n", *format)
+	}
+
+	v := NewValidator()
+	records, problems := 0, 0
+	validate := func(rr RecordReader) error {
+		for {
+			rec, err := rr.ReadRecord()
+			if err == io.EOF {
+				return nil
+			}
+			if rec == nil {
+				return err
+			}
+			records++
+			findings := v.check(rec, err)
+			for _, f := range findings {
+				if err := write(f); err != nil {
+					return err
+				}
+			}
+			problems += len(findings)
+		}
+	}
+
+	if err := eachInput(fs.Args(), validate); err != nil {
+		log.Fatalf("[INFO] Error reading surveys: %s\n", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d problem(s) found in %d record(s)\n", problems, records)
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	inputs := []struct{ name, text string }{
+		{"a.txt", `course=RH124
+instructor=Ann Lee
+start_date=2016-09-01
+Q207=4
+=
+course=RH124
+instructor=Ann Lee
+start_date=2016-13-01
+surveydate=yesterday
+Q207=five
+Q208=9
+Q2-09=N/A
+Q999=1
+survey_ver=7
+=
+instructor=Bob Smith
+no equals sign
+=
+`},
+		// A duplicate of a.txt's first record, in another field order.
+		{"b.txt", `Q207=4
+start_date=2016-09-01
+instructor=Ann Lee
+course=RH124
+=
+`},
+	}
+	type finding struct {
+		File   string
+		Line   int
+		Record int
+		Field  string
+		Check  string
+	}
+	want := []finding{
+		{"a.txt", 10, 2, "Q207", CheckInvalidAnswer},
+		{"a.txt", 11, 2, "Q208", CheckOutOfRange},
+		{"a.txt", 13, 2, "Q999", CheckUnknownField},
+		{"a.txt", 8, 2, "start_date", CheckInvalidDate},
+		{"a.txt", 9, 2, "surveydate", CheckInvalidDate},
+		{"a.txt", 14, 2, "survey_ver", CheckUnknownVersion},
+		{"a.txt", 17, 3, "", CheckSyntax},
+		{"a.txt", 16, 3, "course", CheckMissingField},
+		{"a.txt", 16, 3, "start_date", CheckMissingField},
+		{"b.txt", 1, 1, "", CheckDuplicate},
+	}
+
+	v := NewValidator()
+	var got []finding
+	for _, in := range inputs {
+		d := NewDecoder(strings.NewReader(in.text))
+		d.Name = in.name
+		for {
+			rec, err := d.ReadRecord()
+			if err == io.EOF {
+				break
+			}
+			if rec == nil {
+				t.Fatal(err)
+			}
+			for _, f := range v.check(rec, err) {
+				got = append(got, finding{f.File, f.Line, f.Record, f.Field, f.Check})
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings:\n%v\nwant:\n%v", got, want)
+	}
+}
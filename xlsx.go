@@ -0,0 +1,303 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sniffXLSX reports whether head looks like the start of an XLSX file,
+// which is a zip archive.
+func sniffXLSX(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("PK\x03\x04"))
+}
+
+// openXLSX returns a RecordReader reading records from the first worksheet
+// of the XLSX workbook in r, with a header row and one row per survey. The
+// whole workbook is read into memory, since zip archives cannot be read as a
+// stream.
+func openXLSX(r io.Reader, name string) (RecordReader, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := readXLSX(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	i := 0
+	next := func() ([]string, int, error) {
+		if i == len(rows) {
+			return nil, 0, io.EOF
+		}
+		i++
+		return rows[i-1].cells, rows[i-1].number, nil
+	}
+	return newTableReader(name, next)
+}
+
+// An xlsxRow is a row of a worksheet.
+type xlsxRow struct {
+	number int // Row number, starting at 1.
+	cells  []string
+}
+
+// XML elements of an XLSX workbook used by readXLSX. Only the parts needed
+// to read cell values are declared.
+type (
+	xlsxWorkbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	xlsxRelationships struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	xlsxSharedStrings struct {
+		Items []xlsxText `xml:"si"`
+	}
+	xlsxText struct {
+		T    string `xml:"t"`
+		Runs []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	}
+	xlsxStyles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	xlsxWorksheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				R      string   `xml:"r,attr"`
+				T      string   `xml:"t,attr"`
+				S      int      `xml:"s,attr"`
+				V      string   `xml:"v"`
+				Inline xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+)
+
+// String returns the text of a shared or inline string, which is either
+// plain or made of rich text runs.
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var s string
+	for _, r := range t.Runs {
+		s += r.T
+	}
+	return s
+}
+
+// readXLSX returns the rows of the first worksheet of the XLSX workbook b,
+// with every cell value as text. Numbers formatted as dates are returned as
+// dates in a layout parseDate understands.
+func readXLSX(b []byte) ([]xlsxRow, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	decode := func(name string, v interface{}) error {
+		f, ok := files[name]
+		if !ok {
+			return fmt.Errorf("missing %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		if err := xml.NewDecoder(rc).Decode(v); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		return nil
+	}
+
+	var wb xlsxWorkbook
+	var rels xlsxRelationships
+	if err := decode("xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+	if err := decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	if len(wb.Sheets) == 0 {
+		return nil, fmt.Errorf("workbook has no worksheets")
+	}
+	sheet := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == wb.Sheets[0].ID {
+			sheet = rel.Target
+		}
+	}
+	if sheet == "" {
+		return nil, fmt.Errorf("first worksheet not found")
+	}
+	if strings.HasPrefix(sheet, "/") {
+		sheet = strings.TrimPrefix(sheet, "/")
+	} else {
+		sheet = path.Join("xl", sheet)
+	}
+
+	// Shared strings and styles are optional.
+	var sst xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decode("xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+	}
+	var styles xlsxStyles
+	if _, ok := files["xl/styles.xml"]; ok {
+		if err := decode("xl/styles.xml", &styles); err != nil {
+			return nil, err
+		}
+	}
+	customFormats := make(map[int]string)
+	for _, f := range styles.NumFmts {
+		customFormats[f.ID] = f.Code
+	}
+	isDate := func(style int) bool {
+		if style < 0 || style >= len(styles.CellXfs) {
+			return false
+		}
+		id := styles.CellXfs[style].NumFmtID
+		if code, ok := customFormats[id]; ok {
+			return isDateFormat(code)
+		}
+		return id >= 14 && id <= 22 || id >= 45 && id <= 47
+	}
+
+	var ws xlsxWorksheet
+	if err := decode(sheet, &ws); err != nil {
+		return nil, err
+	}
+	var rows []xlsxRow
+	for i, r := range ws.Rows {
+		row := xlsxRow{number: r.R}
+		if row.number == 0 {
+			row.number = i + 1
+		}
+		for j, c := range r.Cells {
+			col := j
+			if c.R != "" {
+				if col, err = columnIndex(c.R); err != nil {
+					return nil, fmt.Errorf("%s: %s", sheet, err)
+				}
+			}
+			var v string
+			switch c.T {
+			case "s":
+				n, err := strconv.Atoi(c.V)
+				if err != nil || n < 0 || n >= len(sst.Items) {
+					return nil, fmt.Errorf("%s: cell %s: invalid shared string %q", sheet, c.R, c.V)
+				}
+				v = sst.Items[n].String()
+			case "inlineStr":
+				v = c.Inline.String()
+			case "b":
+				v = "FALSE"
+				if c.V == "1" {
+					v = "TRUE"
+				}
+			case "", "n":
+				v = c.V
+				if f, err := strconv.ParseFloat(c.V, 64); err == nil && isDate(c.S) {
+					v = excelDate(f)
+				}
+			default:
+				v = c.V
+			}
+			for len(row.cells) <= col {
+				row.cells = append(row.cells, "")
+			}
+			row.cells[col] = v
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// columnIndex returns the zero-based column index of a cell reference such
+// as "AB12".
+func columnIndex(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return col - 1, nil
+}
+
+// isDateFormat reports whether a custom number format code formats numbers
+// as dates. Quoted text and bracketed sections such as colors are ignored.
+func isDateFormat(code string) bool {
+	inQuote, inBracket := false, false
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case r == '[':
+			inBracket = true
+		case r == ']':
+			inBracket = false
+		case inBracket:
+		case r == 'd' || r == 'm' || r == 'y':
+			return true
+		}
+	}
+	return false
+}
+
+// excelEpoch is day 0 of Excel's date serial numbers, allowing for its
+// treatment of 1900 as a leap year.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// excelDate formats an Excel date serial number, to the nearest second.
+// Serial numbers before 60, Excel's 29 February 1900, which never was, are a
+// day further from the epoch than later ones; 60 itself is taken as 28
+// February.
+func excelDate(serial float64) string {
+	secs := int64(math.Round(serial * 24 * 60 * 60))
+	days, secs := secs/(24*60*60), secs%(24*60*60)
+	if days < 60 {
+		days++
+	}
+	t := excelEpoch.AddDate(0, 0, int(days)).Add(time.Duration(secs) * time.Second)
+	if secs == 0 {
+		return t.Format(dateLayouts[0])
+	}
+	return t.Format(dateLayouts[1])
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// testXLSX returns a minimal XLSX workbook holding the given files, along
+// with a workbook whose first worksheet is xl/worksheets/sheet1.xml.
+func testXLSX(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	all := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Responses" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships>
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+	}
+	for name, content := range files {
+		all[name] = content
+	}
+	for name, content := range all {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestReadXLSX(t *testing.T) {
+	b := testXLSX(t, map[string]string{
+		"xl/sharedStrings.xml": `<sst>
+<si><t>course</t></si>
+<si><r><t>Q2</t></r><r><rPr><b/></rPr><t>-07</t></r></si>
+<si><t>RH124</t></si></sst>`,
+		"xl/styles.xml": `<styleSheet>
+<numFmts><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd\ hh:mm"/><numFmt numFmtId="165" formatCode="&quot;day&quot;\ 0"/></numFmts>
+<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs></styleSheet>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+<row r="1">
+  <c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c>
+  <c r="C1" t="inlineStr"><is><t>start_date</t></is></c>
+  <c r="D1" t="inlineStr"><is><r><t>survey</t></r><r><t>date</t></r></is></c>
+  <c r="E1" t="inlineStr"><is><t>Q208</t></is></c>
+  <c r="AA1" t="inlineStr"><is><t>Q109</t></is></c>
+</row>
+<row r="3">
+  <c r="A3" t="s"><v>2</v></c><c r="B3"><v>4</v></c>
+  <c r="C3" s="1"><v>42614</v></c><c r="D3" s="2"><v>42615.5</v></c>
+  <c r="E3" s="3"><v>5</v></c><c r="AA3" t="b"><v>1</v></c>
+</row>
+</sheetData></worksheet>`,
+	})
+	rows, err := readXLSX([]byte(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []string{"course", "Q2-07", "start_date", "surveydate", "Q208"}
+	values := []string{"RH124", "4", "2016-09-01", "2016-09-02 12:00:00", "5"}
+	for i := len(header); i < 26; i++ {
+		header = append(header, "")
+		values = append(values, "")
+	}
+	want := []xlsxRow{
+		{1, append(header, "Q109")},
+		{3, append(values, "TRUE")},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("readXLSX = %v, want %v", rows, want)
+	}
+
+	if _, err := readXLSX([]byte(testXLSX(t, map[string]string{
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="s"><v>0</v></c></row></sheetData></worksheet>`,
+	}))); err == nil {
+		t.Errorf("readXLSX succeeded with a missing shared string")
+	}
+	if _, err := readXLSX([]byte("PK\x03\x04 not really a zip file")); err == nil {
+		t.Errorf("readXLSX succeeded on a corrupt file")
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref string
+		col int
+	}{
+		{"A", 0},
+		{"A1", 0},
+		{"Z", 25},
+		{"AA", 26},
+		{"AB12", 27},
+		{"AZ3", 51},
+		{"BA3", 52},
+		{"XFD1048576", 16383},
+	}
+	for _, tt := range tests {
+		if col, err := columnIndex(tt.ref); err != nil || col != tt.col {
+			t.Errorf("columnIndex(%q) = %d, %v; want %d", tt.ref, col, err, tt.col)
+		}
+	}
+	for _, ref := range []string{"", "12", "a1"} {
+		if _, err := columnIndex(ref); err == nil {
+			t.Errorf("columnIndex(%q) succeeded, want an error", ref)
+		}
+	}
+}
+
+func TestExcelDate(t *testing.T) {
+	tests := []struct {
+		serial float64
+		want   string
+	}{
+		{42614, "2016-09-01"},
+		{42614.99998843, "2016-09-01 23:59:59"},
+		{42614.9999999, "2016-09-02"},
+		{42615.5, "2016-09-02 12:00:00"},
+		{43101.25, "2018-01-01 06:00:00"},
+
+		// Excel counts a 29 February 1900 that never was: serial numbers
+		// before it are a day further from the epoch.
+		{1, "1900-01-01"},
+		{59, "1900-02-28"},
+		{61, "1900-03-01"},
+	}
+	for _, tt := range tests {
+		if got := excelDate(tt.serial); got != tt.want {
+			t.Errorf("excelDate(%v) = %q, want %q", tt.serial, got, tt.want)
+		}
+	}
+}
+
+func TestIsDateFormat(t *testing.T) {
+	tests := []struct {
+		code string
+		date bool
+	}{
+		{"yyyy-mm-dd", true},
+		{`[$-409]mmm d, yyyy;@`, true},
+		{`0.00`, false},
+		{`"day" 0`, false},
+		{`[Red]0;[Blue]-0`, false},
+	}
+	for _, tt := range tests {
+		if got := isDateFormat(tt.code); got != tt.date {
+			t.Errorf("isDateFormat(%q) = %v, want %v", tt.code, got, tt.date)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import "strings"
+
+// yesNo returns the normalised form of an answer to a Yes/No question:
+// "Yes", "No", or "" for blank, N/A and any other answer.
+func yesNo(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "y":
+		return "Yes"
+	case "no", "n":
+		return "No"
+	}
+	return ""
+}
+
+// YesNoStats summarises the answers to one Yes/No question. Blank counts
+// unanswered, N/A and any other answer.
+type YesNoStats struct {
+	ID    string
+	Text  string
+	Yes   int
+	No    int
+	Blank int
+}
+
+// Total returns the number of learners asked the question.
+func (st YesNoStats) Total() int {
+	return st.Yes + st.No + st.Blank
+}
+
+// Percent returns n as a percentage of st.Total, or 0 if nobody was asked.
+func (st YesNoStats) Percent(n int) float64 {
+	if st.Total() == 0 {
+		return 0
+	}
+	return float64(n) / float64(st.Total()) * 100
+}
+
+// newYesNoStats returns the YesNoStats for each of qs over surveys. Only
+// surveys whose version and modality ask a question count towards its
+// stats, and questions asked in none of them, such as virtual-only questions
+// in a report on onsite classes, are left out.
+func newYesNoStats(qs []Question, surveys []*Survey) []YesNoStats {
+	var stats []YesNoStats
+	for _, q := range qs {
+		st := YesNoStats{ID: q.ID, Text: q.Text}
+		for _, s := range surveys {
+			vq, ok := catalog.version(s.SurveyVer).yesNoQuestion(q.ID)
+			if !ok || !vq.applies(s) {
+				continue
+			}
+			switch yesNo(s.value(vq.key())) {
+			case "Yes":
+				st.Yes++
+			case "No":
+				st.No++
+			default:
+				st.Blank++
+			}
+		}
+		if st.Total() > 0 || len(surveys) == 0 {
+			stats = append(stats, st)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestNewYesNoStatsModality(t *testing.T) {
+	surveys := []*Survey{
+		{Modality: "VT", Q1901: "Yes", Q109: "Yes"},
+		{Modality: "ILT", Q1901: "No", Q109: "No"},
+		{Modality: "ILT", Q109: "Yes"},
+	}
+	want := map[string]YesNoStats{
+		"Q109":  {Yes: 2, No: 1},
+		"Q1901": {Yes: 1},
+	}
+	for _, st := range newYesNoStats(catalog.yesNoQuestions(surveys), surveys) {
+		w, ok := want[st.ID]
+		if !ok {
+			continue
+		}
+		if st.Yes != w.Yes || st.No != w.No || st.Blank != w.Blank {
+			t.Errorf("%s: %d yes, %d no, %d blank; want %d, %d, %d", st.ID, st.Yes, st.No, st.Blank, w.Yes, w.No, w.Blank)
+		}
+		delete(want, st.ID)
+	}
+	for id := range want {
+		t.Errorf("%s: not reported", id)
+	}
+
+	// A question asked in none of the classes is left out.
+	onsite := surveys[1:]
+	for _, st := range newYesNoStats(catalog.yesNoQuestions(onsite), onsite) {
+		if st.ID == "Q1901" {
+			t.Errorf("Q1901 reported for onsite classes only: %+v", st)
+		}
+	}
+}